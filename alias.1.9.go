@@ -0,0 +1,17 @@
+//go:build go1.9
+// +build go1.9
+
+package goloader
+
+import "github.com/pkujhd/goloader/link"
+
+type DanglingPointer = link.DanglingPointer
+type SerializeHeader = link.SerializeHeader
+type Inspection = link.Inspection
+type Observer = link.Observer
+type Event = link.Event
+type DisasmInst = link.DisasmInst
+type Arena = link.Arena
+type ModuleStats = link.ModuleStats
+type ModuleInfo = link.ModuleInfo
+type CallError = link.CallError
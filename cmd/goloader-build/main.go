@@ -0,0 +1,106 @@
+// goloader-build compiles a package and the symbols of its dependencies which are not in the host executable,
+// and writes them into a single bundle which can be loaded by goloader.UnSerialize.
+// with -key, the bundle is signed by an ed25519 private key, and verified by goloader.UnSerializeVerified.
+//
+// usage:
+//
+//	goloader-build -e ./host -p ./plugin/inter -o inter.goloader
+//	goloader-build -e ./host -p ./plugin/inter -key build.key -o inter.goloader
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/resolver"
+)
+
+// listPackage builds pkg with go list, the export file of a package is its archive in build cache,
+// goos is the target os of go list, which is set by GOOS in environment
+func listPackage(pkg, tags string) (pkgPath, export, goos string, err error) {
+	args := []string{"list", "-export", "-f", "{{.ImportPath}}\t{{.Name}}\t{{.Export}}\t{{context.GOOS}}"}
+	if tags != constants.EmptyString {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, pkg)
+	cmd := exec.Command("go", args...)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return constants.EmptyString, constants.EmptyString, constants.EmptyString, fmt.Errorf("go list %s error: %v\n%s", pkg, err, stderr.String())
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), "\t")
+	if len(fields) != 4 || fields[2] == constants.EmptyString {
+		return constants.EmptyString, constants.EmptyString, constants.EmptyString, fmt.Errorf("go list %s: no export file", pkg)
+	}
+	pkgPath = fields[0]
+	if fields[1] == constants.DefaultPkgPath {
+		pkgPath = constants.DefaultPkgPath
+	}
+	return pkgPath, fields[2], fields[3], nil
+}
+
+func build(host, pkg, output, tags, keyFile string, dirs []string) error {
+	symPtr := make(map[string]uintptr)
+	if err := goloader.RegSymbolWithPath(symPtr, host); err != nil {
+		return fmt.Errorf("register symbols of %s error: %v", host, err)
+	}
+
+	pkgPath, export, goos, err := listPackage(pkg, tags)
+	if err != nil {
+		return err
+	}
+	linker, err := goloader.ReadObjs([]string{export}, []string{pkgPath})
+	if err != nil {
+		return err
+	}
+	linker.GOOS = goos
+	r := &resolver.Resolver{Dirs: dirs, Tags: tags}
+	if err = r.Resolve(linker, symPtr); err != nil {
+		return err
+	}
+
+	if output == constants.EmptyString {
+		output = filepath.Base(strings.TrimSuffix(pkg, "/")) + ".goloader"
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err = serialize(linker, f, keyFile); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	var host = flag.String("e", "", "host executable which loads the bundle")
+	var pkg = flag.String("p", "", "package to build, an import path or a directory")
+	var output = flag.String("o", "", "output bundle, default is <package>.goloader")
+	var tags = flag.String("tags", "", "build tags passed to go list")
+	var keyFile = flag.String("key", "", "PEM encoded PKCS #8 ed25519 private key to sign the bundle")
+	var dirs = flag.String("I", "", "directories searched for dependence archives before go list, separated by os.PathListSeparator")
+	flag.Parse()
+
+	if *host == "" || *pkg == "" {
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	var dirList []string
+	if *dirs != "" {
+		dirList = filepath.SplitList(*dirs)
+	}
+	if err := build(*host, *pkg, *output, *tags, *keyFile, dirList); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
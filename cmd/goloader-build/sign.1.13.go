@@ -0,0 +1,50 @@
+//go:build go1.13
+// +build go1.13
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+)
+
+// readPrivateKey reads a PEM encoded PKCS #8 ed25519 private key, such as the one written by
+// openssl genpkey -algorithm ed25519
+func readPrivateKey(keyFile string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM encoded private key", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", keyFile, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T is not an ed25519 private key", keyFile, key)
+	}
+	return privateKey, nil
+}
+
+// serialize signs the bundle with the private key in keyFile if it is set
+func serialize(linker *goloader.Linker, writer io.Writer, keyFile string) error {
+	if keyFile == constants.EmptyString {
+		return goloader.Serialize(linker, writer)
+	}
+	privateKey, err := readPrivateKey(keyFile)
+	if err != nil {
+		return err
+	}
+	return goloader.SerializeSigned(linker, writer, privateKey)
+}
@@ -0,0 +1,20 @@
+//go:build go1.8 && !go1.13
+// +build go1.8,!go1.13
+
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+)
+
+// serialize can't sign the bundle, signing needs crypto/ed25519 of go1.13 or later
+func serialize(linker *goloader.Linker, writer io.Writer, keyFile string) error {
+	if keyFile != constants.EmptyString {
+		return errors.New("signing bundles needs go1.13 or later")
+	}
+	return goloader.Serialize(linker, writer)
+}
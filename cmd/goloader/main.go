@@ -0,0 +1,250 @@
+// goloader is a tool to debug go object files and bundles loaded by goloader.
+//
+// usage:
+//
+//	goloader inspect [-json] [-e host] -o file.o:pkgpath [-o dep.a:pkgpath ...]
+//	goloader inspect [-json] [-e host] -b bundle.goloader
+//	goloader disasm [-f func] (-o file.o:pkgpath ... | -b bundle.goloader)
+//
+// disasm loads the objs or the bundle into goloader itself, and prints the relocated code of func,
+// or of every function if func is empty. init functions of the loaded packages are not executed
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/link"
+)
+
+type objFlags struct {
+	files    []string
+	pkgPaths []string
+}
+
+func (f *objFlags) String() string {
+	return strings.Join(f.files, ",")
+}
+
+func (f *objFlags) Set(value string) error {
+	file, pkgPath := value, constants.DefaultPkgPath
+	if index := strings.LastIndexByte(value, ':'); index > 0 {
+		file, pkgPath = value[:index], value[index+1:]
+	}
+	f.files = append(f.files, file)
+	f.pkgPaths = append(f.pkgPaths, pkgPath)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goloader inspect [-json] [-e host] (-o file:pkgpath ... | -b bundle)")
+	fmt.Fprintln(os.Stderr, "       goloader disasm [-f func] (-o file:pkgpath ... | -b bundle)")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "inspect":
+		if err := inspect(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "disasm":
+		if err := disasm(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+func inspect(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	var objs objFlags
+	flags.Var(&objs, "o", "go object file and its package path as file:pkgpath, repeatable")
+	var bundle = flags.String("b", "", "bundle written by goloader.Serialize")
+	var host = flags.String("e", "", "host executable, references registered in it are not reported as unresolved")
+	var asJSON = flags.Bool("json", false, "print as json")
+	flags.Parse(args)
+
+	if (len(objs.files) == 0) == (*bundle == "") {
+		flags.PrintDefaults()
+		return fmt.Errorf("need either -o or -b")
+	}
+
+	// RegSymbol initializes the instruction decoder used by reading objs
+	symPtr := make(map[string]uintptr)
+	var err error
+	if *host != "" {
+		err = goloader.RegSymbolWithPath(symPtr, *host)
+	} else {
+		err = goloader.RegSymbol(make(map[string]uintptr))
+	}
+	if err != nil {
+		return err
+	}
+
+	var inspection *link.Inspection
+	if *bundle != "" {
+		if inspection, err = inspectBundle(*bundle, symPtr); err != nil {
+			return err
+		}
+	} else if inspection, err = goloader.Inspect(objs.files, objs.pkgPaths, symPtr); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inspection)
+	}
+	printInspection(os.Stdout, inspection)
+	return nil
+}
+
+func inspectBundle(path string, symPtr map[string]uintptr) (*link.Inspection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// bundles of another platform, go or goloader version are decoded too, the mismatch is reported
+	header, linker, err := goloader.ReadSerialized(f)
+	if err != nil {
+		if header != nil {
+			return nil, fmt.Errorf("bundle header %+v\n%v", *header, err)
+		}
+		return nil, err
+	}
+	inspection := goloader.InspectLinker(linker, symPtr)
+	inspection.Header = header
+	if err = header.CheckCompatible(); err != nil {
+		inspection.Incompatible = err.Error()
+	}
+	return inspection, nil
+}
+
+func printInspection(w io.Writer, inspection *link.Inspection) {
+	if header := inspection.Header; header != nil {
+		fmt.Fprintf(w, "bundle format %d, %s %s/%s, goloader %s, layout %s\n",
+			header.FormatVersion, header.GoVersion, header.GOOS, header.GOARCH, header.GoloaderVersion, header.LayoutHash)
+	}
+	if inspection.Incompatible != "" {
+		fmt.Fprintf(w, "%s\n", inspection.Incompatible)
+	}
+	fmt.Fprintf(w, "arch %s\n", inspection.Arch)
+	for _, pkg := range inspection.Packages {
+		fmt.Fprintf(w, "\npackage %q", pkg.PkgPath)
+		if pkg.File != "" {
+			fmt.Fprintf(w, " (%s)", pkg.File)
+		}
+		fmt.Fprintln(w)
+		if len(pkg.Imports) > 0 {
+			fmt.Fprintf(w, "  imports: %s\n", strings.Join(pkg.Imports, " "))
+		}
+		if len(pkg.CUFiles) > 0 {
+			fmt.Fprintf(w, "  cu files: %s\n", strings.Join(pkg.CUFiles, " "))
+		}
+		for _, cgoImport := range pkg.CgoImports {
+			fmt.Fprintf(w, "  cgo import: %s -> %s (%s)\n", cgoImport.GoSymName, cgoImport.CSymName, cgoImport.SoName)
+		}
+		if pkg.InitTask != "" {
+			fmt.Fprintf(w, "  init task: %s\n", pkg.InitTask)
+		}
+		fmt.Fprintf(w, "  symbols: %d\n", len(pkg.Symbols))
+		for _, sym := range pkg.Symbols {
+			fmt.Fprintf(w, "    %-12s %s", sym.Kind, sym.Name)
+			if sym.Size > 0 {
+				fmt.Fprintf(w, " size=%d", sym.Size)
+			}
+			if sym.DupOK {
+				fmt.Fprint(w, " dupok")
+			}
+			if sym.Type != "" {
+				fmt.Fprintf(w, " type=%s", sym.Type)
+			}
+			fmt.Fprintln(w)
+			for _, reloc := range sym.Relocs {
+				fmt.Fprintf(w, "      %#x size=%d %s %s%+d\n", reloc.Offset, reloc.Size, reloc.Type, reloc.SymName, reloc.Add)
+			}
+		}
+		if len(pkg.Unresolved) > 0 {
+			fmt.Fprintf(w, "  unresolved: %d\n", len(pkg.Unresolved))
+			for _, name := range pkg.Unresolved {
+				fmt.Fprintf(w, "    %s\n", name)
+			}
+		}
+	}
+}
+
+func disasm(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	var objs objFlags
+	flags.Var(&objs, "o", "go object file and its package path as file:pkgpath, repeatable")
+	var bundle = flags.String("b", "", "bundle written by goloader.Serialize")
+	var funcName = flags.String("f", "", "function to disassemble, default is every function")
+	flags.Parse(args)
+
+	if (len(objs.files) == 0) == (*bundle == "") {
+		flags.PrintDefaults()
+		return fmt.Errorf("need either -o or -b")
+	}
+
+	symPtr := make(map[string]uintptr)
+	if err := goloader.RegSymbol(symPtr); err != nil {
+		return err
+	}
+	var linker *goloader.Linker
+	var err error
+	if *bundle != "" {
+		f, err := os.Open(*bundle)
+		if err != nil {
+			return err
+		}
+		linker, err = goloader.UnSerialize(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if linker, err = goloader.ReadObjs(objs.files, objs.pkgPaths); err != nil {
+		return err
+	}
+	// only the relocated code is printed, init functions of objs must not run inside the command
+	linker.SkipInitialize = true
+	codeModule, err := goloader.Load(linker, symPtr)
+	if err != nil {
+		return err
+	}
+	defer codeModule.Unload()
+
+	names := []string{*funcName}
+	if *funcName == "" {
+		names = names[:0]
+		for name := range codeModule.Syms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		insts, err := codeModule.Disassemble(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("TEXT %s(SB)\n", name)
+		for _, inst := range insts {
+			fmt.Printf("  %s\n", inst)
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ const (
 	OsStdout           = "os.Stdout"
 	DefaultPkgPath     = "main"
 	RuntimeDeferReturn = "runtime.deferreturn"
+	RuntimeTLSG        = "runtime.tls_g"
 )
 
 const EmptyString = ``
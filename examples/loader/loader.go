@@ -8,9 +8,9 @@ import (
 	"runtime"
 	"strings"
 	"sync"
-	"unsafe"
 
 	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/link"
 )
 
 type arrayFlags struct {
@@ -40,6 +40,9 @@ func main() {
 	var parseFile = flag.String("parse", "", "parse go object file")
 	var run = flag.String("run", "main.main", "run function")
 	var times = flag.Int("times", 1, "run count")
+	var prune = flag.Bool("prune", false, "only link the run function and the symbols reachable from it")
+	var trace = flag.Bool("trace", false, "print link phases and far address relocations to stderr")
+	var verify = flag.Bool("verify", false, "verify relocations and pc tables before running")
 
 	flag.Parse()
 
@@ -69,11 +72,20 @@ func main() {
 	goloader.RegTypes(symPtr, runtime.LockOSThread, &w, w.Wait)
 	goloader.RegTypes(symPtr, fmt.Sprint, str)
 
-	linker, err := goloader.ReadObjs(files.File, files.PkgPath)
+	var roots []string
+	if *prune {
+		roots = []string{*run}
+	}
+	var observer link.Observer
+	if *trace {
+		observer = link.WriterObserver(os.Stderr)
+	}
+	linker, err := goloader.ReadObjsWithObserver(files.File, files.PkgPath, roots, observer)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	linker.Verify = *verify
 
 	var mmapByte []byte
 	for i := 0; i < *times; i++ {
@@ -82,13 +94,11 @@ func main() {
 			fmt.Println("Load error:", err)
 			return
 		}
-		runFuncPtr := codeModule.Syms[*run]
-		if runFuncPtr == 0 {
-			fmt.Println("Load error! not find function:", *run)
+		var runFunc func()
+		if err = codeModule.Func(*run, &runFunc); err != nil {
+			fmt.Println("Load error!", err)
 			return
 		}
-		funcPtrContainer := (uintptr)(unsafe.Pointer(&runFuncPtr))
-		runFunc := *(*func())(unsafe.Pointer(&funcPtrContainer))
 		runFunc()
 		os.Stdout.Sync()
 		codeModule.Unload()
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+//go:noinline
+func throw(value int) int {
+	if value > 0 {
+		panic(fmt.Sprintf("panic in module with value %d", value))
+	}
+	return value
+}
+
+// the panic unwinds through throw, which has no defers, before it is recovered in main
+func main() {
+	defer func() {
+		fmt.Println("recovered:", recover())
+	}()
+	throw(1)
+}
@@ -1,6 +1,7 @@
 package goloader
 
 import (
+	"context"
 	"io"
 
 	"github.com/pkujhd/goloader/link"
@@ -20,13 +21,112 @@ func ReadObjs(files []string, pkgPaths []string) (*Linker, error) {
 	return (*Linker)(linker), err
 }
 
+func ReadObjsWithRoots(files []string, pkgPaths []string, roots []string) (*Linker, error) {
+	linker, err := link.ReadObjsWithRoots(files, pkgPaths, roots)
+	return (*Linker)(linker), err
+}
+
+func ReadObjsWithObserver(files []string, pkgPaths []string, roots []string, observer link.Observer) (*Linker, error) {
+	linker, err := link.ReadObjsWithObserver(files, pkgPaths, roots, observer)
+	return (*Linker)(linker), err
+}
+
 func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, err error) {
 	module, err := link.Load((*link.Linker)(linker), symPtr)
 	return (*CodeModule)(module), err
 }
 
-func (codeModule *CodeModule) Unload() {
-	(*link.CodeModule)(codeModule).Unload()
+func LoadWithImports(linker *Linker, symPtr map[string]uintptr, imports ...*CodeModule) (*CodeModule, error) {
+	modules := make([]*link.CodeModule, len(imports))
+	for index, imported := range imports {
+		modules[index] = (*link.CodeModule)(imported)
+	}
+	module, err := link.LoadWithImports((*link.Linker)(linker), symPtr, modules...)
+	return (*CodeModule)(module), err
+}
+
+func (codeModule *CodeModule) Unload() error {
+	return (*link.CodeModule)(codeModule).Unload()
+}
+
+func (codeModule *CodeModule) UnloadCascade() error {
+	return (*link.CodeModule)(codeModule).UnloadCascade()
+}
+
+func (codeModule *CodeModule) Exports() map[string]uintptr {
+	return (*link.CodeModule)(codeModule).Exports()
+}
+
+func toCodeModules(modules []*link.CodeModule) []*CodeModule {
+	codeModules := make([]*CodeModule, len(modules))
+	for index, module := range modules {
+		codeModules[index] = (*CodeModule)(module)
+	}
+	return codeModules
+}
+
+func (codeModule *CodeModule) Imports() []*CodeModule {
+	return toCodeModules((*link.CodeModule)(codeModule).Imports())
+}
+
+func (codeModule *CodeModule) Dependents() []*CodeModule {
+	return toCodeModules((*link.CodeModule)(codeModule).Dependents())
+}
+
+func (codeModule *CodeModule) InUse() bool {
+	return (*link.CodeModule)(codeModule).InUse()
+}
+
+func (codeModule *CodeModule) UnloadSafe() error {
+	return (*link.CodeModule)(codeModule).UnloadSafe()
+}
+
+func (codeModule *CodeModule) Stats() link.ModuleStats {
+	return (*link.CodeModule)(codeModule).Stats()
+}
+
+func (codeModule *CodeModule) Info() link.ModuleInfo {
+	return (*link.CodeModule)(codeModule).Info()
+}
+
+func Modules() []link.ModuleInfo {
+	return link.Modules()
+}
+
+func ModuleForPC(pc uintptr) *CodeModule {
+	return (*CodeModule)(link.ModuleForPC(pc))
+}
+
+func (codeModule *CodeModule) DanglingPointers() ([]link.DanglingPointer, error) {
+	return (*link.CodeModule)(codeModule).DanglingPointers()
+}
+
+func (codeModule *CodeModule) Disassemble(name string) ([]link.DisasmInst, error) {
+	return (*link.CodeModule)(codeModule).Disassemble(name)
+}
+
+func (codeModule *CodeModule) Func(name string, fnPtr interface{}) error {
+	return (*link.CodeModule)(codeModule).Func(name, fnPtr)
+}
+
+func (codeModule *CodeModule) Call(ctx context.Context, name string, args ...interface{}) error {
+	return (*link.CodeModule)(codeModule).Call(ctx, name, args...)
+}
+
+func (codeModule *CodeModule) CallFunc(name string, fnPtr interface{}) error {
+	return (*link.CodeModule)(codeModule).CallFunc(name, fnPtr)
+}
+
+func (codeModule *CodeModule) LookupFunc(name string) (interface{}, error) {
+	return (*link.CodeModule)(codeModule).LookupFunc(name)
+}
+
+func (codeModule *CodeModule) LookupVar(name string) (interface{}, error) {
+	return (*link.CodeModule)(codeModule).LookupVar(name)
+}
+
+func (codeModule *CodeModule) Var(name string, ptr interface{}) error {
+	return (*link.CodeModule)(codeModule).Var(name, ptr)
 }
 
 func UnresolvedSymbols(linker *Linker, symPtr map[string]uintptr) []string {
@@ -37,6 +137,10 @@ func ReadDependPackages(linker *Linker, files, pkgPaths []string, symbolNames []
 	return link.ReadDependPackages((*link.Linker)(linker), files, pkgPaths, symbolNames, symPtr)
 }
 
+func NewArena(chunkSize int) *link.Arena {
+	return link.NewArena(chunkSize)
+}
+
 func Mmap(size int) ([]byte, error) {
 	return mmap.Mmap(size)
 }
@@ -49,6 +153,14 @@ func Parse(file, pkgPath string) ([]string, error) {
 	return link.Parse(file, pkgPath)
 }
 
+func Inspect(files []string, pkgPaths []string, symPtr map[string]uintptr) (*link.Inspection, error) {
+	return link.Inspect(files, pkgPaths, symPtr)
+}
+
+func InspectLinker(linker *Linker, symPtr map[string]uintptr) *link.Inspection {
+	return link.InspectLinker((*link.Linker)(linker), symPtr)
+}
+
 func RegSymbol(symPtr map[string]uintptr) error {
 	return link.RegSymbol(symPtr)
 }
@@ -69,3 +181,12 @@ func UnSerialize(reader io.Reader) (*Linker, error) {
 	linker, err := link.UnSerialize(reader)
 	return (*Linker)(linker), err
 }
+
+func ReadSerializeHeader(reader io.Reader) (*link.SerializeHeader, error) {
+	return link.ReadSerializeHeader(reader)
+}
+
+func ReadSerialized(reader io.Reader) (*link.SerializeHeader, *Linker, error) {
+	header, linker, err := link.ReadSerialized(reader)
+	return header, (*Linker)(linker), err
+}
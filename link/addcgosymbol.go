@@ -29,7 +29,8 @@ func (linker *Linker) AddCgoSymbols(symPtr map[string]uintptr) error {
 		}
 	}
 
-	for _, cgoImport := range linker.CgoImportMap {
+	for _, name := range sortedKeys(linker.CgoImportMap) {
+		cgoImport := linker.CgoImportMap[name]
 		ptr, err := libdl.LookupSymbol(soMap[cgoImport.SoName], cgoImport.CSymName)
 		if err != nil {
 			return err
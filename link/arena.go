@@ -0,0 +1,205 @@
+package link
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"github.com/pkujhd/goloader/constants"
+)
+
+const (
+	// DefaultArenaChunkSize is the size of a chunk reserved by an Arena if NewArena is called with 0
+	DefaultArenaChunkSize = 4 << 20
+	// arenaAlign is the alignment of every allocation of an Arena, it is not less than the alignment of functions and data symbols
+	arenaAlign = 64
+)
+
+// arenaSpan is a free range of a chunk
+type arenaSpan struct {
+	off  int
+	size int
+}
+
+// arenaChunk is a mapping of an Arena, its free spans are sorted by offset and never adjacent
+type arenaChunk struct {
+	mem  []byte
+	base uintptr
+	free []arenaSpan
+	used int
+}
+
+func newArenaChunk(mem []byte) *arenaChunk {
+	return &arenaChunk{
+		mem:  mem,
+		base: uintptr(unsafe.Pointer(&mem[0])),
+		free: []arenaSpan{{off: 0, size: len(mem)}},
+	}
+}
+
+// alloc allocates size bytes from the first free span which is large enough
+func (chunk *arenaChunk) alloc(size int) ([]byte, bool) {
+	for index, span := range chunk.free {
+		if span.size < size {
+			continue
+		}
+		if span.size == size {
+			chunk.free = append(chunk.free[:index], chunk.free[index+1:]...)
+		} else {
+			chunk.free[index] = arenaSpan{off: span.off + size, size: span.size - size}
+		}
+		chunk.used += size
+		return chunk.mem[span.off : span.off+size : span.off+size], true
+	}
+	return nil, false
+}
+
+// release returns b to the free spans of chunk, and merges it with its neighbours
+func (chunk *arenaChunk) release(off, size int) {
+	index := sort.Search(len(chunk.free), func(i int) bool { return chunk.free[i].off > off })
+	chunk.free = append(chunk.free, arenaSpan{})
+	copy(chunk.free[index+1:], chunk.free[index:])
+	chunk.free[index] = arenaSpan{off: off, size: size}
+	if index+1 < len(chunk.free) && off+size == chunk.free[index+1].off {
+		chunk.free[index].size += chunk.free[index+1].size
+		chunk.free = append(chunk.free[:index+1], chunk.free[index+2:]...)
+	}
+	if index > 0 && chunk.free[index-1].off+chunk.free[index-1].size == off {
+		chunk.free[index-1].size += chunk.free[index].size
+		chunk.free = append(chunk.free[:index], chunk.free[index+1:]...)
+	}
+	chunk.used -= size
+}
+
+func (chunk *arenaChunk) contains(b []byte) bool {
+	addr := uintptr(unsafe.Pointer(&b[0]))
+	return addr >= chunk.base && addr < chunk.base+uintptr(len(chunk.mem))
+}
+
+// Arena sub-allocates code and data segments of many modules from larger mappings, so that small modules
+// don't take whole pages for each segment. on linux/amd64 the mappings are in the low 32-bit address space as
+// the segments mapped by a Load without Arena.
+// code chunks are always readable, writable and executable, because a page may be shared by a module being
+// relocated and a module being executed, so the modules loaded into an Arena are neither W^X nor read-only protected,
+// Load refuses an Arena unless mmap.WriteXorExecute is false.
+// the space of a module is returned to its Arena by Unload, the mappings of an Arena are never unmapped
+type Arena struct {
+	lock      sync.Mutex
+	chunkSize int
+	code      []*arenaChunk
+	data      []*arenaChunk
+}
+
+// NewArena creates an Arena which reserves chunkSize bytes for code or data each time it runs out of space,
+// chunkSize is aligned to page size, DefaultArenaChunkSize is used if chunkSize is not positive
+func NewArena(chunkSize int) *Arena {
+	if chunkSize <= 0 {
+		chunkSize = DefaultArenaChunkSize
+	}
+	return &Arena{chunkSize: alignof(chunkSize, constants.PageSize)}
+}
+
+func (arena *Arena) alloc(chunks *[]*arenaChunk, size int, mmap func(size int) ([]byte, error)) ([]byte, error) {
+	size = alignof(size, arenaAlign)
+	arena.lock.Lock()
+	defer arena.lock.Unlock()
+	for _, chunk := range *chunks {
+		if b, ok := chunk.alloc(size); ok {
+			return b, nil
+		}
+	}
+	chunkSize := arena.chunkSize
+	if size > chunkSize {
+		chunkSize = alignof(size, constants.PageSize)
+	}
+	mem, err := mmap(chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	chunk := newArenaChunk(mem)
+	*chunks = append(*chunks, chunk)
+	b, _ := chunk.alloc(size)
+	return b, nil
+}
+
+func (arena *Arena) release(chunks []*arenaChunk, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	arena.lock.Lock()
+	defer arena.lock.Unlock()
+	for _, chunk := range chunks {
+		if chunk.contains(b) {
+			chunk.release(int(uintptr(unsafe.Pointer(&b[0]))-chunk.base), cap(b))
+			return nil
+		}
+	}
+	return fmt.Errorf("arena: %p is not allocated by arena", &b[0])
+}
+
+// allocCode allocates a code segment, it is readable, writable and executable
+func (arena *Arena) allocCode(size int) ([]byte, error) {
+	return arena.alloc(&arena.code, size, MmapNear)
+}
+
+// allocData allocates a data segment, it is zeroed
+func (arena *Arena) allocData(size int) ([]byte, error) {
+	return arena.alloc(&arena.data, size, MmapDataNear)
+}
+
+func (arena *Arena) releaseCode(b []byte) error {
+	return arena.release(arena.code, b)
+}
+
+// releaseData clears b, a data segment must be zeroed when it is allocated again for bss
+func (arena *Arena) releaseData(b []byte) error {
+	b = b[:cap(b)]
+	for index := range b {
+		b[index] = 0
+	}
+	return arena.release(arena.data, b)
+}
+
+// Usage returns the bytes reserved by arena and the bytes allocated to loaded modules, for code and data
+func (arena *Arena) Usage() (codeReserved, codeUsed, dataReserved, dataUsed int) {
+	arena.lock.Lock()
+	defer arena.lock.Unlock()
+	for _, chunk := range arena.code {
+		codeReserved += len(chunk.mem)
+		codeUsed += chunk.used
+	}
+	for _, chunk := range arena.data {
+		dataReserved += len(chunk.mem)
+		dataUsed += chunk.used
+	}
+	return
+}
+
+// mapCode maps the code segment of module, or allocates it from the Arena of module
+func (cm *CodeModule) mapCode(size int) ([]byte, error) {
+	if cm.arena != nil {
+		return cm.arena.allocCode(size)
+	}
+	return MmapCodeNear(alignof(size, constants.PageSize))
+}
+
+// mapData maps the data segment of module, or allocates it from the Arena of module
+func (cm *CodeModule) mapData(size int) ([]byte, error) {
+	if cm.arena != nil {
+		return cm.arena.allocData(size)
+	}
+	return MmapDataNear(alignof(size, constants.PageSize))
+}
+
+// unmapSegments unmaps the segments of module, or returns them to the Arena of module
+func (cm *CodeModule) unmapSegments() {
+	if cm.arena != nil {
+		_ = cm.arena.releaseCode(cm.codeByte)
+		_ = cm.arena.releaseData(cm.dataByte)
+	} else {
+		_ = Munmap(cm.codeByte)
+		_ = Munmap(cm.dataByte)
+	}
+	cm.codeByte, cm.dataByte = nil, nil
+}
@@ -53,3 +53,28 @@ var (
 		0xE9, 0x00, 0x00, 0x00, 0x00,
 	}
 )
+
+// riscv64
+var (
+	// register X31 reserved for liblink. see:^src/cmd/objfile/obj/riscv/cpu.go
+	riscv64ReplaceCALLCode = []byte{
+		0x97, 0x0F, 0x00, 0x00, // AUIPC X31, 0 - read PC into X31
+		0x83, 0xBF, 0x0F, 0x01, // LD X31, 16(X31) - read 64 bit address from PC+16 into X31
+		0x67, 0x80, 0x0F, 0x00, // JALR X0, 0(X31) - jump to address in X31
+		0x13, 0x00, 0x00, 0x00, // NOP
+	}
+	riscv64NOPCode  = []byte{0x13, 0x00, 0x00, 0x00} // NOP (ADDI X0, X0, 0)
+	riscv64CNOPCode = []byte{0x01, 0x00}             // C.NOP
+)
+
+// loong64
+var (
+	// register R30 reserved for liblink. see:^src/cmd/objfile/obj/loong64/cpu.go
+	loong64ReplaceCALLCode = []byte{
+		0x1E, 0x00, 0x00, 0x1C, // PCADDU12I R30, 0 - read PC into R30
+		0xDE, 0x43, 0xC0, 0x28, // LD.D R30, R30, 16 - read 64 bit address from PC+16 into R30
+		0xC0, 0x03, 0x00, 0x4C, // JIRL R0, R30, 0 - jump to address in R30
+		0x00, 0x00, 0x40, 0x03, // NOP
+	}
+	loong64NOPCode = []byte{0x00, 0x00, 0x40, 0x03} // NOP (ANDI R0, R0, 0)
+)
@@ -0,0 +1,206 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// CallError is returned by Call and CallFunc if the function panics, faults or does not return before
+// the deadline of context
+type CallError struct {
+	Module string
+	Func   string
+	Panic  interface{} // recovered value, nil if the call timed out
+	Fault  bool        // the panic is a memory fault, e.g. a nil dereference
+	Stack  string      // frames of module on the stack of the panicking goroutine
+	Err    error       // error of context if the call timed out, the call continues in background
+}
+
+func (e *CallError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("call %s in module %s: %v, the call continues in background", e.Func, e.Module, e.Err)
+	case e.Fault:
+		return fmt.Sprintf("call %s in module %s: fault: %v", e.Func, e.Module, e.Panic)
+	default:
+		return fmt.Sprintf("call %s in module %s: panic: %v", e.Func, e.Module, e.Panic)
+	}
+}
+
+// Unwrap returns the error of context, or the recovered value if it is an error
+func (e *CallError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	err, _ := e.Panic.(error)
+	return err
+}
+
+// isFault reports whether a recovered value is raised by a memory fault,
+// see $GOROOT/src/runtime/panic.go panicmem and panicmemAddr
+func isFault(value interface{}) bool {
+	if err, ok := value.(runtime.Error); ok {
+		message := err.Error()
+		return strings.Contains(message, "invalid memory address") || strings.Contains(message, "unexpected fault address")
+	}
+	return false
+}
+
+// moduleStack formats the frames of module on the current stack like a traceback
+func (cm *CodeModule) moduleStack() string {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(1, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	codeStart, codeEnd := uintptr(cm.codeBase), uintptr(cm.codeBase+len(cm.codeByte))
+	buffer := bytes.Buffer{}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.PC >= codeStart && frame.PC < codeEnd {
+			fmt.Fprintf(&buffer, "%s(...)\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return buffer.String()
+		}
+	}
+}
+
+// protectedCall calls fn with recover and debug.SetPanicOnFault, a panic or fault is returned as a CallError
+func (cm *CodeModule) protectedCall(name string, fn reflect.Value, args []reflect.Value) (out []reflect.Value, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if value := recover(); value != nil {
+			err = &CallError{Module: cm.name, Func: name, Panic: value, Fault: isFault(value), Stack: cm.moduleStack()}
+		}
+	}()
+	if fn.Type().IsVariadic() {
+		return fn.CallSlice(args), nil
+	}
+	return fn.Call(args), nil
+}
+
+type callResult struct {
+	out []reflect.Value
+	err error
+}
+
+// call calls fn on the calling goroutine, or on a new goroutine if ctx can be done,
+// then a CallError with the error of ctx is returned when ctx is done before fn returns
+func (cm *CodeModule) call(ctx context.Context, name string, fn reflect.Value, args []reflect.Value) ([]reflect.Value, error) {
+	if cm.codeByte == nil {
+		return nil, fmt.Errorf("func %s: module %s is unloaded", name, cm.name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &CallError{Module: cm.name, Func: name, Err: err}
+	}
+	if ctx.Done() == nil {
+		return cm.protectedCall(name, fn, args)
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		out, err := cm.protectedCall(name, fn, args)
+		done <- callResult{out: out, err: err}
+	}()
+	select {
+	case result := <-done:
+		return result.out, result.err
+	case <-ctx.Done():
+		return nil, &CallError{Module: cm.name, Func: name, Err: ctx.Err()}
+	}
+}
+
+// Call calls the function name in module with args and returns its panic or fault as a CallError.
+// the function has no results and its parameters are the types of args, use CallFunc for other functions.
+// if ctx has a deadline or can be canceled, the function runs on a new goroutine and a CallError with
+// the error of ctx is returned when ctx is done, the call continues in background and keeps module in use.
+// a panic on a goroutine started by the function is not recovered
+func (cm *CodeModule) Call(ctx context.Context, name string, args ...interface{}) error {
+	params := make([]reflect.Type, len(args))
+	values := make([]reflect.Value, len(args))
+	for index, arg := range args {
+		if arg == nil {
+			return fmt.Errorf("func %s: arg %d is nil, its type is unknown, use CallFunc instead", name, index)
+		}
+		params[index] = reflect.TypeOf(arg)
+		values[index] = reflect.ValueOf(arg)
+	}
+	fn, err := cm.funcValue(name, reflect.FuncOf(params, nil, false))
+	if err != nil {
+		return err
+	}
+	_, err = cm.call(ctx, name, fn, values)
+	return err
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// calledFuncType returns the type of function called by a func of typ, which has a context.Context
+// parameter before the parameters of function and an error result after its results
+func calledFuncType(name string, typ reflect.Type) (reflect.Type, error) {
+	if typ.NumIn() == 0 || typ.In(0) != contextType || typ.NumOut() == 0 || typ.Out(typ.NumOut()-1) != errorType {
+		return nil, fmt.Errorf("func %s: %s has no context.Context parameter first or no error result last", name, typ.String())
+	}
+	params := make([]reflect.Type, typ.NumIn()-1)
+	for index := range params {
+		params[index] = typ.In(index + 1)
+	}
+	results := make([]reflect.Type, typ.NumOut()-1)
+	for index := range results {
+		results[index] = typ.Out(index)
+	}
+	return reflect.FuncOf(params, results, typ.IsVariadic()), nil
+}
+
+// CallFunc sets the func variable pointed by fnPtr to a call of the function name in module like Call.
+// the func variable has a context.Context parameter before the parameters of function and an error result
+// after its results, e.g. func(context.Context, int) (string, error) calls func(int) string.
+// a nil context never times out, a CallError is returned by the error result with zero values of other results
+func (cm *CodeModule) CallFunc(name string, fnPtr interface{}) error {
+	ptrValue := reflect.ValueOf(fnPtr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.IsNil() || ptrValue.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("func %s: need a non-nil pointer to a func variable, got %T", name, fnPtr)
+	}
+	typ := ptrValue.Elem().Type()
+	fnType, err := calledFuncType(name, typ)
+	if err != nil {
+		return err
+	}
+	fn, err := cm.funcValue(name, fnType)
+	if err != nil {
+		return err
+	}
+	ptrValue.Elem().Set(reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		ctx, _ := in[0].Interface().(context.Context)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		out, err := cm.call(ctx, name, fn, in[1:])
+		results := make([]reflect.Value, typ.NumOut())
+		for index := range out {
+			results[index] = out[index]
+		}
+		for index := len(out); index < len(results)-1; index++ {
+			results[index] = reflect.Zero(typ.Out(index))
+		}
+		results[len(results)-1] = reflect.Zero(errorType)
+		if err != nil {
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+		}
+		return results
+	}))
+	return nil
+}
@@ -0,0 +1,185 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package link
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+	"github.com/pkujhd/goloader/objabi/symkind"
+)
+
+// the segments of a cross compiled module are never mapped, relocations are applied to byte slices at these addresses
+const (
+	crossCodeBase = 0x40000000
+	crossNearHost = 0x80000 // host symbols are placed after code, within the range of a direct call
+)
+
+// crossFar is how far host symbols and data are moved when relocations are expected to overflow,
+// it is truncated on 32-bit hosts, which skip the tests
+var crossFar uint64 = 1 << 40
+
+// crossTarget is a relocated instruction sequence decoded by an arch specific decoder
+type crossTarget struct {
+	addr uintptr
+	path string // direct, veneer, epilogue or tls, relocations which are not decoded have no path
+}
+
+// crossDecoder decodes the target of a relocated loc, the unrelocated code of module is linker.Code
+type crossDecoder func(t *testing.T, linker *Linker, cm *CodeModule, loc obj.Reloc) crossTarget
+
+// crossStep decodes a pcvalue table like runtime.step with the pc quantum of objs instead of the one of host
+func crossStep(pcQuantum uintptr) func(p []byte, pc *uintptr, val *int32, first bool) ([]byte, bool) {
+	return func(p []byte, pc *uintptr, val *int32, first bool) ([]byte, bool) {
+		uvdelta, n := binary.Uvarint(p)
+		if n <= 0 || uvdelta == 0 && !first {
+			return nil, false
+		}
+		*val += int32(-(uvdelta & 1) ^ (uvdelta >> 1))
+		p = p[n:]
+		pcdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			return nil, false
+		}
+		*pc += uintptr(pcdelta) * pcQuantum
+		return p[n:], true
+	}
+}
+
+// readCrossObjs cross compiles the package made of files for linux/goarch and reads it
+func readCrossObjs(t *testing.T, goarch, pkgPath string, files map[string]string, flags ...string) *Linker {
+	archive := buildCrossObjs(t, goarch, pkgPath, files, flags...)
+	hostStep := step
+	step = crossStep(getPCQuantum(getArch(goarch)))
+	t.Cleanup(func() { step = hostStep })
+	linker, err := ReadObjs([]string{archive}, []string{pkgPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return linker
+}
+
+// buildCrossObjs cross compiles the package made of files for linux/goarch, and returns the path of its archive
+func buildCrossObjs(t *testing.T, goarch, pkgPath string, files map[string]string, flags ...string) string {
+	if uint64(uintptr(crossFar)) != crossFar {
+		t.Skip("relocations of 64-bit objs are not tested on 32-bit hosts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+pkgPath+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(dir, "pkg.a")
+	args := append(append([]string{"build"}, flags...), "-o", archive, ".")
+	cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+goarch, "CGO_ENABLED=0", "GOFLAGS=", "GOWORK=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build %s for %s: %v\n%s", pkgPath, goarch, err, output)
+	}
+	return archive
+}
+
+// relocateCrossObjs lays out the objs of linker like Load and relocates them, without mapping, registering
+// or executing module. host symbols and data segment are moved by crossFar if far is set, so relocations to them overflow
+func relocateCrossObjs(t *testing.T, linker *Linker, far bool) (*CodeModule, map[string]uintptr) {
+	distance := uintptr(0)
+	if far {
+		distance = uintptr(crossFar)
+	}
+	if err := linker.addVeneerSymbol(); err != nil {
+		t.Fatal(err)
+	}
+	cm := &CodeModule{
+		Syms:      make(map[string]uintptr),
+		vars:      make(map[string]variable),
+		funcArgs:  make(map[string]int32),
+		funcTypes: make(map[string]function),
+		exports:   make(map[string]uintptr),
+		relocs:    make(map[string][]obj.Reloc),
+		module:    &moduledata{typemap: nil},
+		arch:      linker.Arch,
+	}
+
+	codeSeg := &cm.segment.codeSeg
+	codeSeg.length = len(linker.Code)
+	codeSeg.codeByte = make([]byte, layoutVeneers(linker, cm))
+	codeSeg.maxLen = len(codeSeg.codeByte)
+	codeSeg.codeBase = crossCodeBase
+	copy(codeSeg.codeByte, linker.Code)
+	codeSeg.codeOff = codeSeg.length
+
+	dataSeg := &cm.segment.dataSeg
+	dataSeg.noPtrItabDataLen = len(linker.NoPtrItabData)
+	dataSeg.bssLen = len(linker.Bss)
+	dataSeg.noPtrBssLen = len(linker.NoPtrBss)
+	layoutReadOnlyData(linker, cm)
+	dataSeg.length = dataSeg.dataLen + dataSeg.noPtrTypeDataLen + dataSeg.noPtrItabDataLen + dataSeg.noPtrDataLen + dataSeg.pclntabLen + dataSeg.bssLen + dataSeg.noPtrBssLen
+	dataSeg.extraDataLen = linker.ExtraData
+	dataSeg.dataByte = make([]byte, dataSeg.length+dataSeg.extraDataLen)
+	dataSeg.maxLen = len(dataSeg.dataByte)
+	dataSeg.dataBase = crossCodeBase + alignof(codeSeg.maxLen, constants.PageSize) + int(distance)
+	dataSeg.dataOff = dataSeg.length
+	dataSeg.gotOff = dataSeg.dataOff
+	dataSeg.dataOff += len(linker.GOTSlots) * constants.PtrSize
+	linker.adaptSymbolOffset(cm)
+
+	symPtr := make(map[string]uintptr)
+	host := uintptr(crossCodeBase+codeSeg.maxLen+crossNearHost) + distance
+	for index, name := range UnresolvedSymbols(linker, symPtr) {
+		symPtr[name] = host + uintptr(index)*0x100
+	}
+	symbolMap, err := linker.addSymbolMap(symPtr, cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = linker.relocate(cm, symbolMap, symPtr); err != nil {
+		t.Fatal(err)
+	}
+	return cm, symbolMap
+}
+
+// checkCrossRelocs decodes every relocation of text symbols of module, checks that it reaches its target,
+// and returns how many relocations of each type are decoded through each path
+func checkCrossRelocs(t *testing.T, linker *Linker, cm *CodeModule, symbolMap map[string]uintptr, decode crossDecoder) map[int]map[string]int {
+	paths := make(map[int]map[string]int)
+	for _, name := range sortedKeys(linker.SymMap) {
+		symbol := linker.SymMap[name]
+		if symbol.Offset == constants.InvalidOffset || !symkind.IsText(symbol.Kind) {
+			continue
+		}
+		interfaceTypeMap := getUseInterfaceTypeMap(symbol)
+		for _, loc := range symbol.Reloc {
+			loc.Type &^= reloctype.R_WEAK
+			target := decode(t, linker, cm, loc)
+			if target.path == constants.EmptyString {
+				continue
+			}
+			expected := uintptr(int(linker.relocSymbolAddr(loc, interfaceTypeMap, symbolMap, &cm.segment)) + loc.Add)
+			if target.path == "tls" {
+				expected = uintptr(loc.Add)
+			}
+			if target.addr != expected {
+				t.Errorf("%s+%#x: %s to %s is relocated to %#x through %s, want %#x",
+					name, loc.Offset, reloctype.RelocTypeString(loc.Type), loc.SymName, target.addr, target.path, expected)
+			}
+			if paths[loc.Type] == nil {
+				paths[loc.Type] = make(map[string]int)
+			}
+			paths[loc.Type][target.path]++
+		}
+	}
+	return paths
+}
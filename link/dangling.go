@@ -0,0 +1,512 @@
+package link
+
+import (
+	"bufio"
+	"cmd/objfile/objfile"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/pkujhd/goloader/constants"
+)
+
+// see $GOROOT/src/runtime/heapdump.go
+const (
+	heapDumpHeader = "go1.7 heap dump\n"
+
+	tagEOF             = 0
+	tagObject          = 1
+	tagOtherRoot       = 2
+	tagType            = 3
+	tagGoroutine       = 4
+	tagStackFrame      = 5
+	tagParams          = 6
+	tagFinalizer       = 7
+	tagItab            = 8
+	tagOSThread        = 9
+	tagMemStats        = 10
+	tagQueuedFinalizer = 11
+	tagData            = 12
+	tagBSS             = 13
+	tagDefer           = 14
+	tagPanic           = 15
+	tagMemProf         = 16
+	tagAllocSample     = 17
+
+	fieldKindEol = 0
+
+	memStatsFieldCount = 24 + 256 + 1
+)
+
+// runtime records every module in these variables, with their sizes in words,
+// they are cleaned by Unload except pinnedTypemaps, which is never released by runtime.
+// itabs of module are recorded in itabRegistrySymbols and removed by Unload too
+var moduleRegistrySymbols = map[string]uint64{
+	"runtime.modulesSlice":      1,
+	"runtime.moduleToTypelinks": 1,
+	"runtime.pinnedTypemaps":    1,
+}
+
+// goloader holds the loaded modules in its registry and a copy of runtime.itabTable, they are not held by host
+var (
+	linkPkgPath             = reflect.TypeOf(CodeModule{}).PkgPath()
+	goloaderRegistrySymbols = []string{linkPkgPath + ".registry", linkPkgPath + ".itabTable"}
+)
+
+// syscall.mapper points to the mmapper which records every mapping until munmap
+const (
+	syscallMapper     = "syscall.mapper"
+	syscallMapperSize = 4 * constants.PtrSize
+)
+
+// DanglingPointer is a pointer held by host which points into the code or data of a module
+type DanglingPointer struct {
+	Holder  string  // host symbol, stack frame or heap object holding the pointer
+	Address uintptr // address of the pointer
+	Target  uintptr // address in module which the pointer points to
+}
+
+func (p DanglingPointer) String() string {
+	return fmt.Sprintf("%s at 0x%x points to 0x%x", p.Holder, p.Address, p.Target)
+}
+
+type addrRange struct {
+	start, end uint64
+}
+
+func (ar addrRange) contains(p uint64) bool {
+	return p >= ar.start && p < ar.end
+}
+
+type hostSymbol struct {
+	name string
+	addr uint64
+}
+
+// readHostDataSymbols reads data and bss symbols of host executable, sorted by address
+func readHostDataSymbols() ([]hostSymbol, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	f, err := objfile.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	symbols, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	//Address space layout randomization(ASLR)
+	addrOff := int64(0)
+	for _, sym := range symbols {
+		if sym.Name == constants.OsStdout {
+			addrOff = int64(uintptr(unsafe.Pointer(&os.Stdout))) - int64(sym.Addr)
+		}
+	}
+	hostSymbols := make([]hostSymbol, 0)
+	for _, sym := range symbols {
+		code := strings.ToUpper(string(sym.Code))
+		if code == "D" || code == "B" {
+			hostSymbols = append(hostSymbols, hostSymbol{name: sym.Name, addr: uint64(int64(sym.Addr) + addrOff)})
+		}
+	}
+	sort.Slice(hostSymbols, func(i, j int) bool { return hostSymbols[i].addr < hostSymbols[j].addr })
+	return hostSymbols, nil
+}
+
+func hostSymbolName(symbols []hostSymbol, addr uint64) string {
+	index := sort.Search(len(symbols), func(i int) bool { return symbols[i].addr > addr })
+	if index == 0 {
+		return fmt.Sprintf("0x%x", addr)
+	}
+	return fmt.Sprintf("%s+0x%x", symbols[index-1].name, addr-symbols[index-1].addr)
+}
+
+type heapDumpHit struct {
+	off    uint64
+	target uint64
+}
+
+// heapDumpObject is a heap object, a stack frame or another root of heap dump
+type heapDumpObject struct {
+	addr   uint64
+	size   uint64
+	ptrs   []uint64      // values of pointer fields, used to walk the heap
+	offs   []uint64      // offsets of pointer fields
+	hits   []heapDumpHit // words pointing into module
+	holder string
+	root   string
+}
+
+func (o *heapDumpObject) holderName() string {
+	if o.root != constants.EmptyString {
+		return fmt.Sprintf("%s reachable from %s", o.holder, o.root)
+	}
+	return o.holder
+}
+
+type heapDumpReader struct {
+	*bufio.Reader
+	byteOrder binary.ByteOrder
+	ptrSize   int
+	ranges    []addrRange
+	err       error
+}
+
+func (r *heapDumpReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.Reader)
+	r.err = err
+	return v
+}
+
+func (r *heapDumpReader) bytes() []byte {
+	n := r.uint()
+	if r.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, r.err = io.ReadFull(r.Reader, b)
+	return b
+}
+
+func (r *heapDumpReader) skip(n int) {
+	for i := 0; i < n; i++ {
+		r.uint()
+	}
+}
+
+func (r *heapDumpReader) word(b []byte, off uint64) uint64 {
+	if off+uint64(r.ptrSize) > uint64(len(b)) {
+		return 0
+	}
+	if r.ptrSize == constants.Uint32Size {
+		return uint64(r.byteOrder.Uint32(b[off:]))
+	}
+	return r.byteOrder.Uint64(b[off:])
+}
+
+func (r *heapDumpReader) inModule(p uint64) bool {
+	for _, ar := range r.ranges {
+		if ar.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// contents records every word of contents pointing into module, whether it is a pointer field or not,
+// because type words of interfaces and code pointers of closures are not pointer fields for gc
+func (r *heapDumpReader) contents(o *heapDumpObject) []byte {
+	contents := r.bytes()
+	o.size = uint64(len(contents))
+	for off := uint64(0); off+uint64(r.ptrSize) <= o.size; off += uint64(r.ptrSize) {
+		if word := r.word(contents, off); r.inModule(word) {
+			o.hits = append(o.hits, heapDumpHit{off: off, target: word})
+		}
+	}
+	return contents
+}
+
+func (r *heapDumpReader) fields(o *heapDumpObject, contents []byte) {
+	for kind := r.uint(); kind != fieldKindEol && r.err == nil; kind = r.uint() {
+		off := r.uint()
+		o.offs = append(o.offs, off)
+		o.ptrs = append(o.ptrs, r.word(contents, off))
+	}
+}
+
+// pointers records the pointers of a root which has no memory contents, such as a finalizer or a defer
+func (r *heapDumpReader) pointers(o *heapDumpObject, n int) *heapDumpObject {
+	for i := 0; i < n; i++ {
+		p := r.uint()
+		o.ptrs = append(o.ptrs, p)
+		o.offs = append(o.offs, 0)
+		if r.inModule(p) {
+			o.hits = append(o.hits, heapDumpHit{off: 0, target: p})
+		}
+	}
+	return o
+}
+
+// currentGoroutineID parses the id of the calling goroutine from the header of its stack trace
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := strings.Fields(string(buf))
+	if len(fields) < 2 {
+		return 0
+	}
+	goid, _ := strconv.ParseUint(fields[1], 10, 64)
+	return goid
+}
+
+type heapDumpFrame struct {
+	name  string
+	entry uint64 // entry of function
+	pc    uint64
+}
+
+type heapDumpGoroutine struct {
+	id         uint64
+	waitReason string
+	frames     []heapDumpFrame // complete stack, innermost first
+}
+
+type heapDump struct {
+	*heapDumpReader
+	objects    []*heapDumpObject
+	roots      []*heapDumpObject // other roots, stack frames, finalizers and defers
+	segments   []*heapDumpObject // data and bss of host
+	goroutines []*heapDumpGoroutine
+}
+
+// readHeapDump dumps the heap of host and reads it, words pointing into module are recorded as hits.
+// heap objects are not kept if goroutinesOnly is set, frames reported by skipFrame are not roots
+func (cm *CodeModule) readHeapDump(goroutinesOnly bool, skipFrame func(goid uint64, funcName string) bool) (*heapDump, error) {
+	file, err := ioutil.TempFile(constants.EmptyString, "goloader-heapdump")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if !goroutinesOnly {
+		runtime.GC()
+	}
+	debug.WriteHeapDump(file.Fd())
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r := &heapDumpReader{
+		Reader:    bufio.NewReaderSize(file, 1<<20),
+		byteOrder: binary.LittleEndian,
+		ptrSize:   constants.PtrSize,
+		ranges: []addrRange{
+			{uint64(cm.codeBase), uint64(cm.codeBase + len(cm.codeByte))},
+			{uint64(cm.dataBase), uint64(cm.dataBase + len(cm.dataByte))},
+		},
+	}
+	header := make([]byte, len(heapDumpHeader))
+	if _, err = io.ReadFull(r.Reader, header); err != nil || string(header) != heapDumpHeader {
+		return nil, fmt.Errorf("unsupported heap dump header %q, error: %v", header, err)
+	}
+
+	dump := &heapDump{heapDumpReader: r}
+	// stack frames follow the goroutine they belong to
+	var goroutine *heapDumpGoroutine
+	for tag := r.uint(); tag != tagEOF && r.err == nil; tag = r.uint() {
+		switch tag {
+		case tagObject:
+			object := &heapDumpObject{addr: r.uint()}
+			object.holder = fmt.Sprintf("heap object 0x%x", object.addr)
+			r.fields(object, r.contents(object))
+			if !goroutinesOnly {
+				dump.objects = append(dump.objects, object)
+			}
+		case tagOtherRoot:
+			description := string(r.bytes())
+			dump.roots = append(dump.roots, r.pointers(&heapDumpObject{holder: description}, 1))
+		case tagType:
+			r.skip(2)
+			r.bytes()
+			r.skip(1)
+		case tagGoroutine:
+			r.skip(2)
+			goroutine = &heapDumpGoroutine{id: r.uint()}
+			r.skip(5)
+			goroutine.waitReason = string(r.bytes())
+			r.skip(4)
+			dump.goroutines = append(dump.goroutines, goroutine)
+		case tagStackFrame:
+			frame := &heapDumpObject{addr: r.uint()}
+			r.skip(2)
+			contents := r.contents(frame)
+			entry, pc := r.uint(), r.uint()
+			r.skip(1)
+			funcName := string(r.bytes())
+			frame.holder = "stack frame of " + funcName
+			r.fields(frame, contents)
+			goid := uint64(0)
+			if goroutine != nil {
+				goroutine.frames = append(goroutine.frames, heapDumpFrame{name: funcName, entry: entry, pc: pc})
+				goid = goroutine.id
+			}
+			if skipFrame == nil || !skipFrame(goid, funcName) {
+				dump.roots = append(dump.roots, frame)
+			}
+		case tagParams:
+			if r.uint() != 0 {
+				r.byteOrder = binary.BigEndian
+			}
+			r.ptrSize = int(r.uint())
+			r.skip(2)
+			r.bytes()
+			r.bytes()
+			r.skip(1)
+		case tagFinalizer, tagQueuedFinalizer:
+			holder := fmt.Sprintf("finalizer of 0x%x", r.uint())
+			dump.roots = append(dump.roots, r.pointers(&heapDumpObject{holder: holder}, 2))
+			r.skip(2)
+		case tagItab:
+			r.skip(2)
+		case tagOSThread:
+			r.skip(3)
+		case tagMemStats:
+			r.skip(memStatsFieldCount)
+		case tagData, tagBSS:
+			segment := &heapDumpObject{addr: r.uint()}
+			r.fields(segment, r.contents(segment))
+			dump.segments = append(dump.segments, segment)
+		case tagDefer:
+			holder := fmt.Sprintf("defer 0x%x", r.uint())
+			r.skip(3)
+			dump.roots = append(dump.roots, r.pointers(&heapDumpObject{holder: holder}, 2))
+			r.skip(1)
+		case tagPanic:
+			r.skip(6)
+		case tagMemProf:
+			r.skip(2)
+			nstk := r.uint()
+			for i := uint64(0); i < nstk && r.err == nil; i++ {
+				r.bytes()
+				r.bytes()
+				r.skip(1)
+			}
+			r.skip(2)
+		case tagAllocSample:
+			r.skip(2)
+		default:
+			return nil, fmt.Errorf("unknown heap dump tag %d", tag)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("read heap dump error: %v", r.err)
+	}
+	if len(dump.segments) == 0 {
+		return nil, errors.New("no data and bss segment in heap dump")
+	}
+	return dump, nil
+}
+
+// DanglingPointers dumps the heap of host and reports every word held by the data and bss of host,
+// goroutine stacks, finalizers, defers and the heap objects reachable from them which points into code or data of module.
+// pointers held by data of other loaded modules, by the registries of modules and itabs in runtime and goloader,
+// and by the frames of goloader on the calling goroutine are not reported.
+// strings in stringMap of module are allocated on heap and stay valid after unload, so they are not reported.
+func (cm *CodeModule) DanglingPointers() ([]DanglingPointer, error) {
+	hostSymbols, err := readHostDataSymbols()
+	if err != nil {
+		return nil, err
+	}
+	excludeRoots := make([]addrRange, 0)
+	for _, sym := range hostSymbols {
+		if words, ok := moduleRegistrySymbols[sym.name]; ok {
+			excludeRoots = append(excludeRoots, addrRange{sym.addr, sym.addr + words*constants.PtrSize})
+		}
+		if words, ok := itabRegistrySymbols[sym.name]; ok {
+			excludeRoots = append(excludeRoots, addrRange{sym.addr, sym.addr + words*constants.PtrSize})
+		}
+		for _, name := range goloaderRegistrySymbols {
+			if sym.name == name {
+				excludeRoots = append(excludeRoots, addrRange{sym.addr, sym.addr + constants.PtrSize})
+			}
+		}
+		if sym.name == syscallMapper {
+			mapper := uint64(*(*uintptr)(adduintptr(uintptr(sym.addr), 0)))
+			excludeRoots = append(excludeRoots, addrRange{mapper, mapper + syscallMapperSize})
+		}
+	}
+	// frames of goloader on the calling goroutine, such as UnloadSafe and DanglingPointers, hold module
+	selfGoid := currentGoroutineID()
+	excludeObjects := map[uint64]bool{
+		uint64(uintptr(unsafe.Pointer(cm))):        true,
+		uint64(uintptr(unsafe.Pointer(cm.module))): true,
+	}
+
+	dump, err := cm.readHeapDump(false, func(goid uint64, funcName string) bool {
+		return goid == selfGoid && strings.HasPrefix(funcName, linkPkgPath+".")
+	})
+	if err != nil {
+		return nil, err
+	}
+	objects, roots := dump.objects, dump.roots
+
+	isExcludeRoot := func(addr uint64) bool {
+		for _, excludeRoot := range excludeRoots {
+			if excludeRoot.contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	// split data and bss into one root per word, for naming the holder by host symbol
+	for _, segment := range dump.segments {
+		for _, hit := range segment.hits {
+			if !isExcludeRoot(segment.addr + hit.off) {
+				roots = append(roots, &heapDumpObject{
+					addr:   segment.addr + hit.off,
+					hits:   []heapDumpHit{{off: 0, target: hit.target}},
+					holder: hostSymbolName(hostSymbols, segment.addr+hit.off),
+				})
+			}
+		}
+		for index, off := range segment.offs {
+			if !isExcludeRoot(segment.addr+off) && !dump.inModule(segment.ptrs[index]) {
+				roots = append(roots, &heapDumpObject{
+					addr:   segment.addr + off,
+					ptrs:   segment.ptrs[index : index+1],
+					offs:   []uint64{0},
+					holder: hostSymbolName(hostSymbols, segment.addr+off),
+				})
+			}
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].addr < objects[j].addr })
+	findObject := func(p uint64) *heapDumpObject {
+		index := sort.Search(len(objects), func(i int) bool { return objects[i].addr > p })
+		if index > 0 && p < objects[index-1].addr+objects[index-1].size {
+			return objects[index-1]
+		}
+		return nil
+	}
+
+	pointers := make([]DanglingPointer, 0)
+	visited := make(map[*heapDumpObject]bool)
+	queue := roots
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		for _, hit := range o.hits {
+			pointers = append(pointers, DanglingPointer{Holder: o.holderName(), Address: uintptr(o.addr + hit.off), Target: uintptr(hit.target)})
+		}
+		for _, p := range o.ptrs {
+			if child := findObject(p); child != nil && !visited[child] && !excludeObjects[child.addr] {
+				visited[child] = true
+				child.root = o.holder
+				if o.root != constants.EmptyString {
+					child.root = o.root
+				}
+				queue = append(queue, child)
+			}
+		}
+	}
+	runtime.KeepAlive(cm)
+	return pointers, nil
+}
@@ -23,7 +23,7 @@ func (linker *Linker) addDeferReturn(_func *_func, module *moduledata) (err erro
 				switch linker.Arch.Name {
 				case sys.Arch386.Name, sys.ArchAMD64.Name:
 					_func.Deferreturn = uint32(r.Offset) - uint32(sym.Offset) - 1
-				case sys.ArchARM.Name, sys.ArchARM64.Name:
+				case sys.ArchARM.Name, sys.ArchARM64.Name, sys.ArchRISCV64.Name, archNameLOONG64:
 					_func.Deferreturn = uint32(r.Offset) - uint32(sym.Offset)
 				default:
 					err = fmt.Errorf("not support arch:%s", linker.Arch.Name)
@@ -0,0 +1,145 @@
+package link
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/objabi/symkind"
+)
+
+// moduleDeps records which loaded modules a module imports symbols from, and which modules import from it
+type moduleDeps struct {
+	imports    []*CodeModule
+	dependents []*CodeModule
+}
+
+var moduleDepsLock sync.Mutex
+
+// Exports returns the functions, variables and types defined by module, which can be imported by LoadWithImports
+func (cm *CodeModule) Exports() map[string]uintptr {
+	exports := make(map[string]uintptr, len(cm.exports))
+	for name, addr := range cm.exports {
+		exports[name] = addr
+	}
+	return exports
+}
+
+// Imports returns the modules which module imports symbols from
+func (cm *CodeModule) Imports() []*CodeModule {
+	moduleDepsLock.Lock()
+	defer moduleDepsLock.Unlock()
+	return append([]*CodeModule{}, cm.imports...)
+}
+
+// Dependents returns the loaded modules which import symbols from module
+func (cm *CodeModule) Dependents() []*CodeModule {
+	moduleDepsLock.Lock()
+	defer moduleDepsLock.Unlock()
+	return append([]*CodeModule{}, cm.dependents...)
+}
+
+func (cm *CodeModule) checkDependents() error {
+	if dependents := cm.Dependents(); len(dependents) > 0 {
+		return fmt.Errorf("module is still imported by %d module(s), unload them first or use UnloadCascade", len(dependents))
+	}
+	return nil
+}
+
+// LoadWithImports loads linker like Load, the symbols which are not in symPtr are also resolved from
+// the exports of imports, the first import which exports a symbol wins.
+// the imports which module really uses are recorded, UnloadSafe refuses to unload an imported module
+// until all its dependents are unloaded, UnloadCascade unloads the dependents first.
+func LoadWithImports(linker *Linker, symPtr map[string]uintptr, imports ...*CodeModule) (*CodeModule, error) {
+	scope := make(map[string]uintptr, len(symPtr))
+	for name, addr := range symPtr {
+		scope[name] = addr
+	}
+	providers := make(map[string]*CodeModule)
+	for _, imported := range imports {
+		for name, addr := range imported.exports {
+			if _, ok := scope[name]; !ok {
+				scope[name] = addr
+				providers[name] = imported
+			}
+		}
+	}
+
+	used := make(map[*CodeModule]bool)
+	for name, sym := range linker.SymMap {
+		if symkind.IsText(sym.Kind) && sym.Offset != constants.InvalidOffset {
+			continue
+		}
+		if provider, ok := providers[strings.TrimSuffix(name, constants.GOTPCRELSuffix)]; ok {
+			used[provider] = true
+		}
+	}
+
+	codeModule, err := Load(linker, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleDepsLock.Lock()
+	defer moduleDepsLock.Unlock()
+	for _, imported := range imports {
+		if used[imported] {
+			codeModule.imports = append(codeModule.imports, imported)
+			imported.dependents = append(imported.dependents, codeModule)
+			delete(used, imported)
+		}
+	}
+	return codeModule, nil
+}
+
+func (cm *CodeModule) removeFromImports() {
+	moduleDepsLock.Lock()
+	defer moduleDepsLock.Unlock()
+	for _, imported := range cm.imports {
+		for index, dependent := range imported.dependents {
+			if dependent == cm {
+				imported.dependents = append(imported.dependents[:index], imported.dependents[index+1:]...)
+				break
+			}
+		}
+	}
+	cm.imports = nil
+}
+
+// cascade returns module and all modules depending on it, every module is after its dependents
+func (cm *CodeModule) cascade(modules *[]*CodeModule, seen map[*CodeModule]bool) {
+	if seen[cm] {
+		return
+	}
+	seen[cm] = true
+	for index := len(cm.dependents) - 1; index >= 0; index-- {
+		cm.dependents[index].cascade(modules, seen)
+	}
+	*modules = append(*modules, cm)
+}
+
+// UnloadCascade unloads the modules depending on module in reverse dependency order, then unloads module.
+// nothing is unloaded if any of them is still in use by a goroutine
+func (cm *CodeModule) UnloadCascade() error {
+	modules := make([]*CodeModule, 0)
+	moduleDepsLock.Lock()
+	cm.cascade(&modules, make(map[*CodeModule]bool))
+	moduleDepsLock.Unlock()
+
+	for _, module := range modules {
+		goroutines, err := module.usedGoroutines()
+		if err != nil {
+			return err
+		}
+		if len(goroutines) > 0 {
+			return fmt.Errorf("module is still in use by %d goroutine(s):\n%s", len(goroutines), strings.Join(goroutines, "\n"))
+		}
+	}
+	for _, module := range modules {
+		if err := module.Unload(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
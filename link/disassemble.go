@@ -0,0 +1,102 @@
+package link
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+// DisasmInst is an instruction of relocated code, Notes describe the relocations applied to it
+// and the epilogue trampolines it belongs to
+type DisasmInst struct {
+	PC     uintptr
+	Offset int // offset from the entry of function
+	Bytes  []byte
+	Text   string
+	Notes  []string
+}
+
+func (inst DisasmInst) String() string {
+	s := fmt.Sprintf("%#x\t+%#x\t%-24x\t%s", inst.PC, inst.Offset, inst.Bytes, inst.Text)
+	if len(inst.Notes) > 0 {
+		s += "\t// " + strings.Join(inst.Notes, "; ")
+	}
+	return s
+}
+
+// funcRange returns the offset range of function name in code segment, the epilogues of a function follow its body
+func (cm *CodeModule) funcRange(name string) (start, end int, ok bool) {
+	entry, ok := cm.Syms[name]
+	if !ok {
+		return 0, 0, false
+	}
+	start = int(entry) - cm.codeBase
+	end = cm.codeSeg.length
+	for _, addr := range cm.Syms {
+		if offset := int(addr) - cm.codeBase; offset > start && offset < end {
+			end = offset
+		}
+	}
+	return start, end, true
+}
+
+// lookupSymbol names the function which contains addr, in host or in any loaded module
+func lookupSymbol(addr uint64) (string, uint64) {
+	if f := runtime.FuncForPC(uintptr(addr)); f != nil {
+		return f.Name(), uint64(f.Entry())
+	}
+	return "", 0
+}
+
+func relocNote(loc obj.Reloc) string {
+	return fmt.Sprintf("%s %s%+d", reloctype.RelocTypeString(loc.Type), loc.SymName, loc.Add)
+}
+
+// Disassemble decodes the relocated code of function name, the instructions of an overflowed relocation
+// in the epilogue are annotated with its target, an epilogue which is not used is filled with nops
+func (cm *CodeModule) Disassemble(name string) ([]DisasmInst, error) {
+	start, end, ok := cm.funcRange(name)
+	if !ok {
+		return nil, fmt.Errorf("func %s: not found in module", name)
+	}
+	relocs := make([]obj.Reloc, 0, len(cm.relocs[name]))
+	for _, loc := range cm.relocs[name] {
+		if loc.Size > 0 || loc.Epilogue.Size > 0 {
+			relocs = append(relocs, loc)
+		}
+	}
+	sort.Slice(relocs, func(i, j int) bool { return relocs[i].Offset < relocs[j].Offset })
+
+	insts := make([]DisasmInst, 0)
+	for pc := start; pc < end; {
+		text, size, err := obj.Disassemble(cm.codeByte[pc:end], uint64(cm.codeBase+pc), cm.arch, lookupSymbol)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 || pc+size > end {
+			size = end - pc
+		}
+		inst := DisasmInst{PC: uintptr(cm.codeBase + pc), Offset: pc - start, Bytes: cm.codeByte[pc : pc+size], Text: text}
+		for _, loc := range relocs {
+			if loc.Offset >= pc && loc.Offset < pc+size {
+				inst.Notes = append(inst.Notes, relocNote(loc))
+			}
+			epilogue := loc.Epilogue
+			if epilogue.Size > 0 && pc >= epilogue.Offset && pc < epilogue.Offset+epilogue.Size {
+				if bytes.Equal(cm.codeByte[epilogue.Offset:epilogue.Offset+epilogue.Size], createArchNops(cm.arch, epilogue.Size)) {
+					inst.Notes = append(inst.Notes, fmt.Sprintf("unused epilogue of +%#x", loc.Offset-start))
+				} else {
+					inst.Notes = append(inst.Notes, fmt.Sprintf("epilogue of +%#x: %s", loc.Offset-start, relocNote(loc)))
+				}
+			}
+		}
+		insts = append(insts, inst)
+		pc += size
+	}
+	return insts, nil
+}
@@ -26,7 +26,7 @@ func initfunctab(entry, funcoff, text uintptr) functab {
 func addfuncdata(module *moduledata, Func *obj.Func, _func *_func) {
 	funcdata := make([]uint32, 0)
 	for _, v := range Func.FuncData {
-		if v != 0 {
+		if v != constants.InvalidHandleValue {
 			funcdata = append(funcdata, (uint32)(v))
 		} else {
 			funcdata = append(funcdata, ^uint32(0))
@@ -26,10 +26,10 @@ func initfunctab(entry, funcoff, text uintptr) functab {
 func addfuncdata(module *moduledata, Func *obj.Func, _func *_func) {
 	funcdata := make([]uintptr, 0)
 	for _, v := range Func.FuncData {
-		if v != 0 {
+		if v != constants.InvalidHandleValue {
 			funcdata = append(funcdata, v+module.noptrdata)
 		} else {
-			funcdata = append(funcdata, v)
+			funcdata = append(funcdata, 0)
 		}
 	}
 	grow(&module.pclntable, alignof(len(module.pclntable), constants.PtrSize))
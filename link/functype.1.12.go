@@ -0,0 +1,28 @@
+//go:build go1.12
+// +build go1.12
+
+package link
+
+import (
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+)
+
+// readExportData reads the export data of package pkgPath from file, it returns nil if file has no export data
+func readExportData(file, pkgPath string) *types.Package {
+	lookup := func(path string) (io.ReadCloser, error) {
+		if path != pkgPath {
+			return nil, fmt.Errorf("package %s is not in %s", path, file)
+		}
+		return os.Open(file)
+	}
+	pkg, err := importer.ForCompiler(token.NewFileSet(), "gc", lookup).Import(pkgPath)
+	if err != nil {
+		return nil
+	}
+	return pkg
+}
@@ -0,0 +1,11 @@
+//go:build go1.8 && !go1.12
+// +build go1.8,!go1.12
+
+package link
+
+import "go/types"
+
+// importer can't read export data from a file before go1.12, functions are only checked by args size
+func readExportData(file, pkgPath string) *types.Package {
+	return nil
+}
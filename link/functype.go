@@ -0,0 +1,112 @@
+package link
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+)
+
+// funcTypeName formats the type of an exported function like resolveTypeName, so it can be compared with
+// the name of a reflect.Type, or looked up as a type symbol. struct and non-empty interface literals and
+// generic types are not formatted, ok is false then
+func funcTypeName(typ types.Type) (name string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			name, ok = constants.EmptyString, false
+		}
+	}()
+	return formatTypeName(typ), true
+}
+
+func formatTypeName(typ types.Type) string {
+	switch t := typ.(type) {
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return "unsafe.Pointer"
+		}
+		if t.Info()&types.IsUntyped != 0 {
+			panic("untyped type " + t.String())
+		}
+		// byte and rune are aliases of uint8 and int32
+		return types.Typ[t.Kind()].Name()
+	case *types.Named:
+		if strings.Contains(t.String(), "[") {
+			panic("generic type " + t.String())
+		}
+		if t.Obj().Pkg() == nil {
+			return t.Obj().Name()
+		}
+		return obj.PathToPrefix(t.Obj().Pkg().Path()) + "." + t.Obj().Name()
+	case *types.Pointer:
+		return "*" + formatTypeName(t.Elem())
+	case *types.Slice:
+		return "[]" + formatTypeName(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), formatTypeName(t.Elem()))
+	case *types.Map:
+		return fmt.Sprintf("map[%s]%s", formatTypeName(t.Key()), formatTypeName(t.Elem()))
+	case *types.Chan:
+		switch t.Dir() {
+		case types.SendOnly:
+			return "chan<- " + formatTypeName(t.Elem())
+		case types.RecvOnly:
+			return "<-chan " + formatTypeName(t.Elem())
+		default:
+			return "chan " + formatTypeName(t.Elem())
+		}
+	case *types.Signature:
+		ins := make([]string, t.Params().Len())
+		for i := range ins {
+			if i == len(ins)-1 && t.Variadic() {
+				ins[i] = "..." + formatTypeName(t.Params().At(i).Type().(*types.Slice).Elem())
+			} else {
+				ins[i] = formatTypeName(t.Params().At(i).Type())
+			}
+		}
+		outs := make([]string, t.Results().Len())
+		for i := range outs {
+			outs[i] = formatTypeName(t.Results().At(i).Type())
+		}
+		name := "func(" + strings.Join(ins, ", ") + ")"
+		switch len(outs) {
+		case 0:
+			return name
+		case 1:
+			return name + " " + outs[0]
+		default:
+			return name + " (" + strings.Join(outs, ", ") + ")"
+		}
+	case *types.Interface:
+		if t.NumMethods() == 0 {
+			return "interface {}"
+		}
+	}
+	// an alias, e.g. any, is formatted as its aliased type
+	if alias, ok := typ.(interface{ Rhs() types.Type }); ok {
+		return formatTypeName(alias.Rhs())
+	}
+	panic("unsupported type " + typ.String())
+}
+
+// addFuncTypes records the types of exported functions of package pkgPath, which are read from the export data of file
+func (linker *Linker) addFuncTypes(file, pkgPath string) {
+	if pkgPath == constants.EmptyString {
+		pkgPath = constants.DefaultPkgPath
+	}
+	pkg := readExportData(file, pkgPath)
+	if pkg == nil {
+		return
+	}
+	prefix := obj.PathToPrefix(pkgPath) + "."
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if fn, ok := scope.Lookup(name).(*types.Func); ok && fn.Exported() {
+			if typeName, ok := funcTypeName(fn.Type()); ok {
+				linker.FuncTypes[prefix+name] = typeName
+			}
+		}
+	}
+}
@@ -30,6 +30,13 @@ type itabTableType struct {
 	entries [itabInitSize]*itab // really [size] large
 }
 
+// itabRegistrySymbols are the variables recording itabs with their sizes in words, see DanglingPointers.
+// runtime.itabTable points to runtime.itabTableInit until the table grows
+var itabRegistrySymbols = map[string]uint64{
+	"runtime.itabTable":     1,
+	"runtime.itabTableInit": uint64(unsafe.Sizeof(itabTableType{}) / constants.PtrSize),
+}
+
 func removeitabs(module *moduledata) bool {
 	lock(itabLock)
 	defer unlock(itabLock)
@@ -26,6 +26,9 @@ const hashSize = 1009
 //go:linkname hash runtime.hash
 var hash [hashSize]*itab
 
+// itabRegistrySymbols are the variables recording itabs with their sizes in words, see DanglingPointers
+var itabRegistrySymbols = map[string]uint64{"runtime.hash": hashSize}
+
 //go:linkname ifaceLock runtime.ifaceLock
 var ifaceLock mutex
 
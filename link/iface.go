@@ -112,7 +112,8 @@ func hasInvalidMethod(typ *_type, methods map[string]string) bool {
 in golang linker, if a method in types not used, it is deleted by dead code checker.
 if the method is loaded by the goloader, the goloader needs to add a fake itab for avoiding panic in getitab
 */
-func addFakeItabs(symMap map[string]*obj.Sym, symbolMap, symPtr map[string]uintptr, unImplementedTypes map[string]map[string]int, codeModule *CodeModule) {
+func addFakeItabs(symMap map[string]*obj.Sym, symbolMap, symPtr map[string]uintptr, unImplementedTypes map[string]map[string]int, codeModule *CodeModule, observer Observer) int {
+	count := 0
 	for typ, interMap := range unImplementedTypes {
 		for inter := range interMap {
 			if symPtr[typ] != uintptr(0) && symMap[typ] != nil && symbolMap[inter] != uintptr(0) {
@@ -124,8 +125,11 @@ func addFakeItabs(symMap map[string]*obj.Sym, symbolMap, symPtr map[string]uintp
 					lock(itabLock)
 					itabAdd(it)
 					unlock(itabLock)
+					notify(observer, Event{Kind: EventFakeItab, SymName: typ, Target: inter, Addr: uintptr(unsafe.Pointer(it)), Epilogue: -1})
+					count++
 				}
 			}
 		}
 	}
+	return count
 }
@@ -26,8 +26,8 @@ func isNeedInitTaskInPlugin(name string) bool {
 func doInit(t unsafe.Pointer) // t should be a *runtime.initTask
 
 func (linker *Linker) doInitialize(symPtr, symbolMap map[string]uintptr) error {
-	for _, pkg := range linker.Packages {
-		name := getInitFuncName(pkg.PkgPath)
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		name := getInitFuncName(pkgPath)
 		if funcPtr, ok := symbolMap[name]; ok {
 			doInit(adduintptr(funcPtr, 0))
 		}
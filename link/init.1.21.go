@@ -80,9 +80,9 @@ func (linker *Linker) isDependent(pkgPath string) bool {
 }
 
 func (linker *Linker) getEntryPackage() *obj.Pkg {
-	for _, pkg := range linker.Packages {
-		if linker.isDependent(pkg.PkgPath) {
-			return pkg
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		if linker.isDependent(pkgPath) {
+			return linker.Packages[pkgPath]
 		}
 	}
 	return nil
@@ -23,8 +23,8 @@ func isNeedInitTaskInPlugin(name string) bool {
 }
 
 func (linker *Linker) doInitialize(symPtr, symbolMap map[string]uintptr) error {
-	for _, pkg := range linker.Packages {
-		name := getInitFuncName(pkg.PkgPath)
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		name := getInitFuncName(pkgPath)
 		if funcPtr, ok := symbolMap[name]; ok {
 			funcPtrContainer := (uintptr)(unsafe.Pointer(&funcPtr))
 			runFunc := *(*func())(unsafe.Pointer(&funcPtrContainer))
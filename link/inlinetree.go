@@ -47,7 +47,7 @@ func (linker *Linker) addInlineTree(_func *_func, symbol *obj.ObjSymbol) (err er
 		linker.NoPtrData = append(linker.NoPtrData, bytes...)
 		bytearrayAlign(&linker.NoPtrData, constants.PtrSize)
 		for _func.Nfuncdata <= dataindex.FUNCDATA_InlTree {
-			sym.Func.FuncData = append(sym.Func.FuncData, uintptr(0))
+			sym.Func.FuncData = append(sym.Func.FuncData, constants.InvalidHandleValue)
 			_func.Nfuncdata++
 		}
 		sym.Func.FuncData[dataindex.FUNCDATA_InlTree] = (uintptr)(offset)
@@ -0,0 +1,188 @@
+package link
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+	"github.com/pkujhd/goloader/objabi/symkind"
+)
+
+type InspectReloc struct {
+	Offset  int    `json:"offset"`
+	Size    int    `json:"size"`
+	Type    string `json:"type"`
+	SymName string `json:"symName"`
+	Add     int    `json:"add,omitempty"`
+}
+
+type InspectSymbol struct {
+	Name   string         `json:"name"`
+	Kind   string         `json:"kind"`
+	Size   int64          `json:"size"`
+	DupOK  bool           `json:"dupOK,omitempty"`
+	Type   string         `json:"type,omitempty"`
+	Relocs []InspectReloc `json:"relocs,omitempty"`
+}
+
+type InspectPackage struct {
+	PkgPath    string           `json:"pkgPath"`
+	File       string           `json:"file,omitempty"`
+	Imports    []string         `json:"imports,omitempty"`
+	CUFiles    []string         `json:"cuFiles,omitempty"`
+	CgoImports []obj.CgoImport  `json:"cgoImports,omitempty"`
+	InitTask   string           `json:"initTask,omitempty"`
+	Symbols    []*InspectSymbol `json:"symbols"`
+	Unresolved []string         `json:"unresolved,omitempty"`
+}
+
+// Inspection describes the packages of objs or of a serialized Linker
+type Inspection struct {
+	Header *SerializeHeader `json:"header,omitempty"`
+	// Incompatible is the reason why a bundle can not be loaded by current process
+	Incompatible string            `json:"incompatible,omitempty"`
+	Arch         string            `json:"arch"`
+	Packages     []*InspectPackage `json:"packages"`
+}
+
+func inspectRelocs(relocs []obj.Reloc) []InspectReloc {
+	inspectRelocs := make([]InspectReloc, 0, len(relocs))
+	for _, reloc := range relocs {
+		inspectRelocs = append(inspectRelocs, InspectReloc{
+			Offset:  reloc.Offset,
+			Size:    reloc.Size,
+			Type:    reloctype.RelocTypeString(reloc.Type),
+			SymName: reloc.SymName,
+			Add:     reloc.Add,
+		})
+	}
+	return inspectRelocs
+}
+
+func newInspectPackage(pkg *obj.Pkg, defined func(name string) bool) *InspectPackage {
+	inspectPkg := &InspectPackage{
+		PkgPath: pkg.PkgPath,
+		File:    pkg.File,
+		Imports: pkg.ImportPkgs,
+		CUFiles: pkg.CUFiles,
+		Symbols: make([]*InspectSymbol, 0),
+	}
+	for _, name := range sortedKeys(pkg.CgoImports) {
+		inspectPkg.CgoImports = append(inspectPkg.CgoImports, *pkg.CgoImports[name])
+	}
+	if initFuncName := getInitFuncName(pkg.PkgPath); defined(initFuncName) {
+		inspectPkg.InitTask = initFuncName
+	}
+	return inspectPkg
+}
+
+// unresolved returns the references of symbols which are neither defined nor in symPtr
+func unresolved(symbols []*InspectSymbol, defined func(name string) bool, symPtr map[string]uintptr) []string {
+	names := make(map[string]bool)
+	isUnresolved := func(name string) bool {
+		if name == constants.EmptyString || defined(name) {
+			return false
+		}
+		_, ok := symPtr[strings.TrimSuffix(name, constants.GOTPCRELSuffix)]
+		return !ok && !isPreprocessSymbol(name) && !strings.HasPrefix(name, constants.TypeImportPathPrefix)
+	}
+	for _, sym := range symbols {
+		if isUnresolved(sym.Type) {
+			names[sym.Type] = true
+		}
+		for _, reloc := range sym.Relocs {
+			if isUnresolved(reloc.SymName) {
+				names[reloc.SymName] = true
+			}
+		}
+	}
+	return sortedKeys(names)
+}
+
+// Inspect reads objs and describes every package and symbol of them,
+// a reference is unresolved if it is neither defined in objs nor in symPtr, symPtr can be nil
+func Inspect(files []string, pkgPaths []string, symPtr map[string]uintptr) (*Inspection, error) {
+	linker := initLinker()
+	for i, file := range files {
+		if err := linker.readObj(file, pkgPaths[i]); err != nil {
+			return nil, err
+		}
+	}
+	pkgSymbols := make(map[string][]*obj.ObjSymbol)
+	linker.resolvePkgSymbols(func(pkg *obj.Pkg) {
+		for _, name := range sortedKeys(pkg.Syms) {
+			pkgSymbols[pkg.PkgPath] = append(pkgSymbols[pkg.PkgPath], pkg.Syms[name])
+		}
+	})
+	defined := func(name string) bool {
+		_, ok := linker.ObjSymbolMap[name]
+		return ok
+	}
+
+	inspection := &Inspection{Arch: linker.Arch.Name, Packages: make([]*InspectPackage, 0)}
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		inspectPkg := newInspectPackage(linker.Packages[pkgPath], defined)
+		for _, objSym := range pkgSymbols[pkgPath] {
+			inspectPkg.Symbols = append(inspectPkg.Symbols, &InspectSymbol{
+				Name:   objSym.Name,
+				Kind:   symkind.SymKindString(objSym.Kind),
+				Size:   objSym.Size,
+				DupOK:  objSym.DupOK,
+				Type:   objSym.Type,
+				Relocs: inspectRelocs(objSym.Reloc),
+			})
+		}
+		sort.Slice(inspectPkg.Symbols, func(i, j int) bool { return inspectPkg.Symbols[i].Name < inspectPkg.Symbols[j].Name })
+		inspectPkg.Unresolved = unresolved(inspectPkg.Symbols, defined, symPtr)
+		inspection.Packages = append(inspection.Packages, inspectPkg)
+	}
+	return inspection, nil
+}
+
+// InspectLinker describes a Linker, the symbols of a Linker are not grouped by package any more,
+// they are assigned to the package whose path prefixes their name, the others are in a package with empty path.
+// the size of a symbol is not recorded by Linker, so it is zero
+func InspectLinker(linker *Linker, symPtr map[string]uintptr) *Inspection {
+	defined := func(name string) bool {
+		sym, ok := linker.SymMap[name]
+		return ok && sym.Offset != constants.InvalidOffset
+	}
+	inspection := &Inspection{Packages: make([]*InspectPackage, 0)}
+	if linker.Arch != nil {
+		inspection.Arch = linker.Arch.Name
+	}
+	prefixes := make(map[string]*InspectPackage)
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		inspectPkg := newInspectPackage(linker.Packages[pkgPath], defined)
+		inspection.Packages = append(inspection.Packages, inspectPkg)
+		prefixes[obj.PathToPrefix(pkgPath)+"."] = inspectPkg
+	}
+	others := &InspectPackage{Symbols: make([]*InspectSymbol, 0)}
+	for _, name := range sortedKeys(linker.SymMap) {
+		sym := linker.SymMap[name]
+		if sym.Offset == constants.InvalidOffset {
+			continue
+		}
+		inspectPkg, matched := others, constants.EmptyString
+		for prefix, pkg := range prefixes {
+			if strings.HasPrefix(name, prefix) && len(prefix) > len(matched) {
+				inspectPkg, matched = pkg, prefix
+			}
+		}
+		inspectPkg.Symbols = append(inspectPkg.Symbols, &InspectSymbol{
+			Name:   name,
+			Kind:   symkind.SymKindString(sym.Kind),
+			Type:   sym.Type,
+			Relocs: inspectRelocs(sym.Reloc),
+		})
+	}
+	if len(others.Symbols) > 0 {
+		inspection.Packages = append(inspection.Packages, others)
+	}
+	for _, inspectPkg := range inspection.Packages {
+		inspectPkg.Unresolved = unresolved(inspectPkg.Symbols, defined, symPtr)
+	}
+	return inspection
+}
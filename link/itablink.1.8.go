@@ -23,7 +23,7 @@ func regsiterItablinks(symPtr map[string]uintptr) {
 }
 
 func (linker *Linker) AddItabLink(codeModule *CodeModule, symbolMap map[string]uintptr) {
-	for symbolName, _ := range linker.SymMap {
+	for _, symbolName := range sortedKeys(linker.SymMap) {
 		//fill itablinks
 		if isItabName(symbolName) {
 			codeModule.module.itablinks = append(codeModule.module.itablinks, (*itab)(adduintptr(symbolMap[symbolName], 0)))
@@ -11,6 +11,7 @@ import (
 	"unsafe"
 
 	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/mmap"
 	"github.com/pkujhd/goloader/obj"
 	"github.com/pkujhd/goloader/objabi/funcalign"
 	"github.com/pkujhd/goloader/objabi/reloctype"
@@ -21,11 +22,13 @@ import (
 // ourself defined struct
 // code segment
 type codeSeg struct {
-	codeByte []byte
-	codeBase int
-	length   int
-	maxLen   int
-	codeOff  int
+	codeByte  []byte
+	codeBase  int
+	length    int
+	maxLen    int
+	codeOff   int
+	veneerOff int
+	veneerLen int
 }
 
 // data segment
@@ -41,7 +44,9 @@ type dataSeg struct {
 	pclntabLen       int
 	bssLen           int
 	noPtrBssLen      int
+	gotOff           int
 	dataOff          int
+	extraDataLen     int
 }
 
 type segment struct {
@@ -59,7 +64,19 @@ type CodeModule struct {
 	gcData
 	Syms      map[string]uintptr
 	stringMap map[string]*string
-	module    *moduledata
+	vars      map[string]variable
+	funcArgs  map[string]int32
+	funcTypes map[string]function
+	exports   map[string]uintptr
+	relocs    map[string][]obj.Reloc
+	moduleDeps
+	symbolCounts
+	moduleInfo
+	module *moduledata
+	arch   *sys.Arch
+	arena  *Arena
+	// checkDanglingPointers is copied from Linker.CheckDanglingPointers
+	checkDanglingPointers bool
 }
 
 type LinkerData struct {
@@ -89,6 +106,26 @@ type Linker struct {
 	ExtraData          int
 	CUOffset           int32
 	AdaptedOffset      bool
+	Observer           Observer // receives events of reading and loading, it is not serialized
+	Verify             bool     // verify relocations and pc tables before code is executable, it is not serialized
+	Arena              *Arena   // sub-allocate segments from Arena instead of mapping them, it is not serialized
+	Name               string   // name of module in registry, it defaults to the packages of objs, it is not serialized
+	SkipInitialize     bool     // don't run init functions, the module is only for inspecting its code, it is not serialized
+	PackedLayout       bool     // data segment is laid out without padding for read-only protection, it is set by Load
+	// GOOS is the target os of objs which is recorded in the header by Serialize, it defaults to runtime.GOOS
+	GOOS string
+	// CheckDanglingPointers makes UnloadSafe refuse to unload module while host holds pointers into it, it is not serialized
+	CheckDanglingPointers bool
+	// FarAddressEpilogues is set if every relocation has a far address epilogue, which is only used if it overflows.
+	// it is always set by ReadObjs, a Linker without it can't be loaded if segments are not in the low address space
+	FarAddressEpilogues bool
+	Veneers             map[string]int // index of the veneer of every far call target
+	GOTSlots            map[string]int // index of the GOT slot of every far address target
+	// NearHost is set by bundles of older versions, page-relative relocations to symbols of objs have no epilogues then.
+	// ReadObjs always reserves the epilogues, they are only used if segments are not placed near host at Load
+	NearHost bool
+	// FuncTypes is the type of every exported function of objs, which is read from their export data
+	FuncTypes map[string]string
 }
 
 // initialize Linker
@@ -102,6 +139,9 @@ func initLinker() *Linker {
 		CgoFuncs:           make(map[string]int),
 		UnImplementedTypes: make(map[string]map[string]int),
 		Packages:           make(map[string]*obj.Pkg),
+		Veneers:            make(map[string]int),
+		GOTSlots:           make(map[string]int),
+		FuncTypes:          make(map[string]string),
 		CUOffset:           0,
 		ExtraData:          0,
 		AdaptedOffset:      false,
@@ -132,20 +172,34 @@ func (linker *Linker) addFiles(files []string) {
 	}
 }
 
-func (linker *Linker) addSymbols() error {
+// addSymbols adds every non-DupOK text symbol when roots is nil,
+// otherwise adds only roots and the symbols reachable from them by relocations.
+// init functions of packages are always added
+func (linker *Linker) addSymbols(roots []string) error {
 	//static_tmp is 0, the compiler of golang does not allocate memory.
 	linker.NoPtrData = append(linker.NoPtrData, make([]byte, constants.IntSize)...)
 	bytearrayAlign(&linker.NoPtrData, constants.PtrSize)
 	linker.NoPtrTypeData = append(linker.NoPtrTypeData, make([]byte, constants.PtrSize)...)
-	for _, objSym := range linker.ObjSymbolMap {
-		if symkind.IsText(objSym.Kind) && objSym.DupOK == false {
-			if _, err := linker.addSymbol(objSym.Name, nil); err != nil {
-				return err
+	if roots == nil {
+		for _, name := range sortedKeys(linker.ObjSymbolMap) {
+			objSym := linker.ObjSymbolMap[name]
+			if symkind.IsText(objSym.Kind) && objSym.DupOK == false {
+				if _, err := linker.addSymbol(objSym.Name, nil); err != nil {
+					return err
+				}
 			}
 		}
 	}
-	for _, pkg := range linker.Packages {
-		initFuncName := getInitFuncName(pkg.PkgPath)
+	for _, name := range roots {
+		if _, ok := linker.ObjSymbolMap[name]; !ok {
+			return fmt.Errorf("root symbol:%s not found", name)
+		}
+		if _, err := linker.addSymbol(name, nil); err != nil {
+			return err
+		}
+	}
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		initFuncName := getInitFuncName(pkgPath)
 		if _, ok := linker.ObjSymbolMap[initFuncName]; ok {
 			if _, err := linker.addSymbol(initFuncName, nil); err != nil {
 				return err
@@ -189,7 +243,9 @@ func (linker *Linker) adaptSymbolOffset(codeModule *CodeModule) {
 			}
 			if sym.Func != nil {
 				for index := range sym.Func.FuncData {
-					sym.Func.FuncData[index] += uintptr(segment.noPtrTypeDataLen + segment.noPtrItabDataLen)
+					if sym.Func.FuncData[index] != constants.InvalidHandleValue {
+						sym.Func.FuncData[index] += uintptr(segment.noPtrTypeDataLen + segment.noPtrItabDataLen)
+					}
 				}
 			}
 		}
@@ -261,6 +317,14 @@ func (linker *Linker) addSymbol(name string, symPtr map[string]uintptr) (symbol
 		symbol.Offset = len(linker.NoPtrBss)
 		linker.NoPtrBss = append(linker.NoPtrBss, objsym.Data...)
 		bytearrayAlign(&linker.NoPtrBss, constants.PtrSize)
+	case symkind.STLSBSS:
+		// runtime.tls_g is the only TLS symbol of go, it is in the TLS block of host,
+		// TLS relocations to it are relocated with their addends only
+		if symbol.Name != constants.RuntimeTLSG {
+			return nil, fmt.Errorf("invalid TLS symbol:%s, only %s is supported", symbol.Name, constants.RuntimeTLSG)
+		}
+		symbol.Offset = constants.InvalidOffset
+		return symbol, nil
 	default:
 		return nil, fmt.Errorf("invalid symbol:%s kind:%d", symbol.Name, symbol.Kind)
 	}
@@ -361,11 +425,18 @@ func (linker *Linker) readFuncData(symbol *obj.ObjSymbol, symPtr map[string]uint
 	pcspOff := len(linker.Pclntable)
 	linker.Pclntable = append(linker.Pclntable, symbol.Func.PCSP...)
 
-	pcfileOff := len(linker.Pclntable)
-	linker.Pclntable = append(linker.Pclntable, symbol.Func.PCFile...)
+	// an absent pcfile or pcline is offset 0, the runtime reads it as unknown
+	pcfileOff := 0
+	if len(symbol.Func.PCFile) > 0 {
+		pcfileOff = len(linker.Pclntable)
+		linker.Pclntable = append(linker.Pclntable, symbol.Func.PCFile...)
+	}
 
-	pclnOff := len(linker.Pclntable)
-	linker.Pclntable = append(linker.Pclntable, symbol.Func.PCLine...)
+	pclnOff := 0
+	if len(symbol.Func.PCLine) > 0 {
+		pclnOff = len(linker.Pclntable)
+		linker.Pclntable = append(linker.Pclntable, symbol.Func.PCLine...)
+	}
 
 	_func := initfunc(symbol, nameOff, pcspOff, pcfileOff, pclnOff, int(symbol.Func.CUOffset))
 	linker.Funcs = append(linker.Funcs, &_func)
@@ -379,9 +450,10 @@ func (linker *Linker) readFuncData(symbol *obj.ObjSymbol, symPtr map[string]uint
 		}
 	}
 
+	// an absent funcdata is InvalidHandleValue, 0 is the offset of the first symbol of NoPtrData
 	for _, name := range symbol.Func.FuncData {
 		if name == constants.EmptyString {
-			Func.FuncData = append(Func.FuncData, (uintptr)(0))
+			Func.FuncData = append(Func.FuncData, constants.InvalidHandleValue)
 		} else {
 			if _, ok := linker.SymMap[name]; !ok {
 				if _, ok := linker.ObjSymbolMap[name]; ok {
@@ -395,7 +467,7 @@ func (linker *Linker) readFuncData(symbol *obj.ObjSymbol, symPtr map[string]uint
 			if sym, ok := linker.SymMap[name]; ok {
 				Func.FuncData = append(Func.FuncData, (uintptr)(sym.Offset))
 			} else {
-				Func.FuncData = append(Func.FuncData, (uintptr)(0))
+				Func.FuncData = append(Func.FuncData, constants.InvalidHandleValue)
 			}
 		}
 	}
@@ -411,13 +483,17 @@ func (linker *Linker) readFuncData(symbol *obj.ObjSymbol, symPtr map[string]uint
 func (linker *Linker) addSymbolMap(symPtr map[string]uintptr, codeModule *CodeModule) (symbolMap map[string]uintptr, err error) {
 	symbolMap = make(map[string]uintptr)
 	segment := &codeModule.segment
-	for name, sym := range linker.SymMap {
+	for _, name := range sortedKeys(linker.SymMap) {
+		sym := linker.SymMap[name]
 		if sym.Offset == constants.InvalidOffset {
 			if ptr, ok := symPtr[sym.Name]; ok {
 				symbolMap[name] = ptr
 			} else if addr, ok := symPtr[strings.TrimSuffix(name, constants.GOTPCRELSuffix)]; ok && isGOTPCRELName(name) {
 				symbolMap[name] = uintptr(segment.dataBase) + uintptr(segment.dataOff)
+				linker.notifyGOTSlot(name, addr, symbolMap[name])
 				putAddressAddOffset(linker.Arch.ByteOrder, segment.dataByte, &segment.dataOff, uint64(addr))
+			} else if sym.Kind == symkind.STLSBSS {
+				symbolMap[name] = 0
 			} else {
 				symbolMap[name] = constants.InvalidHandleValue
 				return nil, fmt.Errorf("unresolve external:%s", sym.Name)
@@ -425,6 +501,7 @@ func (linker *Linker) addSymbolMap(symPtr map[string]uintptr, codeModule *CodeMo
 		} else if symkind.IsText(sym.Kind) {
 			symbolMap[name] = uintptr(sym.Offset + segment.codeBase)
 			codeModule.Syms[sym.Name] = symbolMap[name]
+			codeModule.exports[name] = symbolMap[name]
 		} else if isStringTypeName(sym.Name) {
 			symbolMap[name] = (*stringHeader)(unsafe.Pointer(linker.StringMap[name])).Data
 		} else if isNeedInitTaskInPlugin(name) {
@@ -435,8 +512,24 @@ func (linker *Linker) addSymbolMap(symPtr map[string]uintptr, codeModule *CodeMo
 			symbolMap[name] = symPtr[name]
 		} else {
 			symbolMap[name] = uintptr(sym.Offset + segment.dataBase)
+			codeModule.exports[name] = symbolMap[name]
+			if sym.Type != constants.EmptyString && (symkind.IsData(sym.Kind) || symkind.IsNoPtrData(sym.Kind) || symkind.IsBss(sym.Kind) || sym.Kind == symkind.SNOPTRBSS) {
+				codeModule.vars[name] = variable{addr: symbolMap[name], typeName: sym.Type}
+			}
 		}
 	}
+	for name, v := range codeModule.vars {
+		v.typ = symbolMap[v.typeName]
+		codeModule.vars[name] = v
+	}
+	// a func type is only in module if it is used as a value, host has it if host asserts a func to it
+	for name, typeName := range linker.FuncTypes {
+		typ, ok := symbolMap[constants.TypePrefix+typeName]
+		if !ok {
+			typ = symPtr[constants.TypePrefix+typeName]
+		}
+		codeModule.funcTypes[name] = function{typeName: typeName, typ: typ}
+	}
 	return symbolMap, err
 }
 
@@ -493,6 +586,7 @@ func (linker *Linker) buildModule(codeModule *CodeModule, symbolMap, symPtr map[
 	for index, _func := range linker.Funcs {
 		funcName := getfuncname(_func, module)
 		module.ftab = append(module.ftab, initfunctab(symbolMap[funcName], uintptr(len(module.pclntable)), module.text))
+		codeModule.funcArgs[funcName] = _func.Args
 		if err = linker.addFuncTab(module, linker.Funcs[index], symbolMap); err != nil {
 			return err
 		}
@@ -540,8 +634,9 @@ func (linker *Linker) buildModule(codeModule *CodeModule, symbolMap, symPtr map[
 	moduledataverify1(codeModule.module)
 	modulesinit()
 	typelinksinit()
-	addFakeItabs(linker.SymMap, symbolMap, symPtr, linker.UnImplementedTypes, codeModule)
+	codeModule.fakeItabs = addFakeItabs(linker.SymMap, symbolMap, symPtr, linker.UnImplementedTypes, codeModule, linker.Observer)
 	additabs(codeModule.module)
+	linker.countSymbols(codeModule)
 
 	return err
 }
@@ -549,7 +644,7 @@ func (linker *Linker) buildModule(codeModule *CodeModule, symbolMap, symPtr map[
 func getPclntabLength(linker *Linker, codeModule *CodeModule) int {
 	pclntabLength := len(linker.Pclntable)
 	pclntabLength = alignof(pclntabLength, constants.PtrSize)
-	pclntabLength += codeModule.codeSeg.maxLen / pcbucketsize * FindFuncBucketSize
+	pclntabLength += alignof(codeModule.codeSeg.length, constants.PageSize) / pcbucketsize * FindFuncBucketSize
 	pclntabLength = alignof(pclntabLength, constants.PtrSize)
 	for _, _func := range linker.Funcs {
 		pclntabLength += _FuncSize + int(_func.Npcdata)*constants.Uint32Size + getFuncdataSize(_func)
@@ -565,19 +660,40 @@ func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, er
 		return nil, err
 	}
 
+	// code chunks of an Arena are shared by modules, they can't be switched between writable and executable
+	if linker.Arena != nil && mmap.WriteXorExecute {
+		return nil, errors.New("code of an arena is writable and executable, set mmap.WriteXorExecute = false to load into an arena")
+	}
+	if linker.AdaptedOffset && linker.PackedLayout != (linker.Arena != nil) {
+		return nil, fmt.Errorf("linker is laid out with packed=%v, it can't be loaded with arena=%v", linker.PackedLayout, linker.Arena != nil)
+	}
+	linker.PackedLayout = linker.Arena != nil
+	if err = linker.addVeneerSymbol(); err != nil {
+		return nil, err
+	}
+
 	codeModule = &CodeModule{
-		Syms:   make(map[string]uintptr),
-		module: &moduledata{typemap: nil},
+		Syms:      make(map[string]uintptr),
+		vars:      make(map[string]variable),
+		funcArgs:  make(map[string]int32),
+		funcTypes: make(map[string]function),
+		exports:   make(map[string]uintptr),
+		relocs:    make(map[string][]obj.Reloc),
+		module:    &moduledata{typemap: nil},
+		arch:      linker.Arch,
+		arena:     linker.Arena,
+
+		checkDanglingPointers: linker.CheckDanglingPointers,
 	}
 
 	//init code segment
 	codeSeg := &codeModule.segment.codeSeg
 	codeSeg.length = len(linker.Code)
-	codeSeg.maxLen = alignof(codeSeg.length, constants.PageSize)
-	codeByte, err := Mmap(codeSeg.maxLen)
+	codeByte, err := codeModule.mapCode(layoutVeneers(linker, codeModule))
 	if err != nil {
 		return nil, err
 	}
+	codeSeg.maxLen = len(codeByte)
 	codeSeg.codeByte = codeByte
 	codeSeg.codeBase = int((*sliceHeader)(unsafe.Pointer(&codeByte)).Data)
 	copy(codeSeg.codeByte, linker.Code)
@@ -585,23 +701,29 @@ func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, er
 
 	//init data segment
 	dataSeg := &codeModule.segment.dataSeg
-	dataSeg.dataLen = len(linker.Data)
-	dataSeg.noPtrTypeDataLen = len(linker.NoPtrTypeData)
 	dataSeg.noPtrItabDataLen = len(linker.NoPtrItabData)
-	dataSeg.noPtrDataLen = len(linker.NoPtrData)
 	dataSeg.bssLen = len(linker.Bss)
 	dataSeg.noPtrBssLen = len(linker.NoPtrBss)
-	dataSeg.pclntabLen = getPclntabLength(linker, codeModule)
+	layoutReadOnlyData(linker, codeModule)
 	dataSeg.length = dataSeg.dataLen + dataSeg.noPtrTypeDataLen + dataSeg.noPtrItabDataLen + dataSeg.noPtrDataLen + dataSeg.pclntabLen + dataSeg.bssLen + dataSeg.noPtrBssLen
-	dataSeg.maxLen = alignof(dataSeg.length+linker.ExtraData, constants.PageSize)
 	dataSeg.dataOff = 0
-	dataByte, err := MmapData(dataSeg.maxLen)
+	dataSeg.extraDataLen = linker.ExtraData
+	dataByte, err := codeModule.mapData(dataSeg.length + dataSeg.extraDataLen)
 	if err != nil {
-		_ = Munmap(dataSeg.dataByte)
+		codeModule.unmapSegments()
 		return nil, err
 	}
+	dataSeg.maxLen = len(dataByte)
 	dataSeg.dataByte = dataByte
 	dataSeg.dataBase = int((*sliceHeader)(unsafe.Pointer(&dataByte)).Data)
+	if linker.NearHost && !codeModule.isNearHost() {
+		codeModule.unmapSegments()
+		return nil, fmt.Errorf("segments are not placed near host at %#x and %#x, read objs again to add epilogues", codeSeg.codeBase, dataSeg.dataBase)
+	}
+	if isMmapInLowAddress(linker.Arch.Name) && !linker.FarAddressEpilogues && !(isInLowAddress(codeByte) && isInLowAddress(dataByte)) {
+		codeModule.unmapSegments()
+		return nil, fmt.Errorf("low address space is exhausted, segments are mapped at %#x and %#x, read objs again to add far address epilogues", codeSeg.codeBase, dataSeg.dataBase)
+	}
 	copy(dataSeg.dataByte[dataSeg.dataOff:], linker.Data)
 	dataSeg.dataOff = dataSeg.dataLen
 	copy(dataSeg.dataByte[dataSeg.dataOff:], linker.NoPtrTypeData)
@@ -615,19 +737,48 @@ func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, er
 	dataSeg.dataOff += dataSeg.bssLen
 	copy(dataSeg.dataByte[dataSeg.dataOff:], linker.NoPtrBss)
 	dataSeg.dataOff += dataSeg.noPtrBssLen
+	dataSeg.gotOff = dataSeg.dataOff
+	dataSeg.dataOff += len(linker.GOTSlots) * constants.PtrSize
 
 	codeModule.stringMap = linker.StringMap
 
 	linker.adaptSymbolOffset(codeModule)
 
 	var symbolMap map[string]uintptr
-	if symbolMap, err = linker.addSymbolMap(symPtr, codeModule); err == nil {
-		if err = linker.relocate(codeModule, symbolMap, symPtr); err == nil {
-			if err = linker.buildModule(codeModule, symbolMap, symPtr); err == nil {
-				MakeThreadJITCodeExecutable(uintptr(codeModule.codeBase), codeSeg.maxLen)
-				if err = linker.doInitialize(symPtr, symbolMap); err == nil {
+	end := linker.phase(PhaseLayout, constants.EmptyString)
+	symbolMap, err = linker.addSymbolMap(symPtr, codeModule)
+	if end(err); err == nil {
+		end = linker.phase(PhaseRelocate, constants.EmptyString)
+		err = linker.relocate(codeModule, symbolMap, symPtr)
+		if end(err); err == nil {
+			end = linker.phase(PhaseBuildModule, constants.EmptyString)
+			err = linker.buildModule(codeModule, symbolMap, symPtr)
+			if end(err); err == nil {
+				if linker.Verify {
+					end = linker.phase(PhaseVerify, constants.EmptyString)
+					err = linker.verify(codeModule, symbolMap, symPtr)
+					end(err)
+				}
+				if err == nil {
+					err = codeModule.protect()
+				}
+				if err != nil {
+					codeModule.Unload()
+					return nil, err
+				}
+				MakeThreadJITCodeExecutable(uintptr(codeModule.codeBase), len(codeSeg.codeByte))
+				// register before initializing, so frames of init functions are attributed to module
+				linker.setModuleInfo(codeModule)
+				registerModule(codeModule)
+				if linker.SkipInitialize {
+					return codeModule, nil
+				}
+				end = linker.phase(PhaseInitialize, constants.EmptyString)
+				err = linker.doInitialize(symPtr, symbolMap)
+				if end(err); err == nil {
 					return codeModule, err
 				}
+				unregisterModule(codeModule)
 			}
 		}
 	}
@@ -636,8 +787,9 @@ func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, er
 
 func UnresolvedSymbols(linker *Linker, symPtr map[string]uintptr) []string {
 	unresolvedSymbols := make([]string, 0)
-	for name, sym := range linker.SymMap {
-		if sym.Offset == constants.InvalidOffset {
+	for _, name := range sortedKeys(linker.SymMap) {
+		sym := linker.SymMap[name]
+		if sym.Offset == constants.InvalidOffset && sym.Kind != symkind.STLSBSS {
 			if _, ok := linker.CgoImportMap[name]; !ok {
 				if _, ok := symPtr[sym.Name]; !ok {
 					nName := strings.TrimSuffix(name, constants.GOTPCRELSuffix)
@@ -672,12 +824,23 @@ func checkUnimplementedInterface(linker *Linker, symPtr map[string]uintptr) map[
 	return linker.UnImplementedTypes
 }
 
-func (cm *CodeModule) Unload() {
+// Unload unloads module, it returns an error and leaves module loaded if any loaded module imports it,
+// UnloadCascade unloads them together
+func (cm *CodeModule) Unload() error {
+	if err := cm.checkDependents(); err != nil {
+		return err
+	}
+	cm.unload()
+	return nil
+}
+
+func (cm *CodeModule) unload() {
+	unregisterModule(cm)
 	removeitabs(cm.module)
 	removeModuleToTypelinks(cm.module)
 	runtime.GC()
 	removeModule(cm.module)
 	modulesinit()
-	_ = Munmap(cm.codeByte)
-	_ = Munmap(cm.dataByte)
+	cm.unmapSegments()
+	cm.removeFromImports()
 }
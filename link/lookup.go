@@ -0,0 +1,129 @@
+package link
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/pkujhd/goloader/constants"
+)
+
+type variable struct {
+	addr     uintptr
+	typeName string
+	typ      uintptr
+}
+
+type function struct {
+	typeName string
+	typ      uintptr
+}
+
+// the compiler does not record the type of a function symbol, if its type is not read from export data,
+// only the layout of in/out args is compared with the args size of _func, which can't tell apart args of the same size.
+// see $GOROOT/src/cmd/compile/internal/types/size.go CalcSize TFUNCARGS
+// with register ABI, results passed in registers have no spill slot, so inSize is also acceptable
+func funcArgsSize(typ reflect.Type) (inSize, size int32) {
+	offset := uintptr(0)
+	for i := 0; i < typ.NumIn(); i++ {
+		offset = alignUp(offset, uintptr(typ.In(i).Align()))
+		offset += typ.In(i).Size()
+	}
+	offset = alignUp(offset, constants.PtrSize)
+	inSize = int32(offset)
+	for i := 0; i < typ.NumOut(); i++ {
+		offset = alignUp(offset, uintptr(typ.Out(i).Align()))
+		offset += typ.Out(i).Size()
+	}
+	offset = alignUp(offset, constants.PtrSize)
+	return inSize, int32(offset)
+}
+
+// Func looks up the function name in module and stores it into fnPtr, fnPtr must be a pointer to a func variable.
+// the type of fnPtr is checked against the signature in export data of objs, which is read by go1.12 or later,
+// otherwise for a function of unsupported signature (e.g. with struct literal or generic types), only args size is checked
+func (cm *CodeModule) Func(name string, fnPtr interface{}) error {
+	ptrValue := reflect.ValueOf(fnPtr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.IsNil() || ptrValue.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("func %s: need a non-nil pointer to a func variable, got %T", name, fnPtr)
+	}
+	fn, err := cm.funcValue(name, ptrValue.Elem().Type())
+	if err != nil {
+		return err
+	}
+	ptrValue.Elem().Set(fn)
+	return nil
+}
+
+// funcValue returns the function name in module as a func value of typ
+func (cm *CodeModule) funcValue(name string, typ reflect.Type) (reflect.Value, error) {
+	entry, ok := cm.Syms[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("func %s: not found in module", name)
+	}
+	if fnType, ok := cm.funcTypes[name]; ok {
+		if typeName := resolveTypeName(rtypeOf(typ)); typeName != fnType.typeName {
+			return reflect.Value{}, fmt.Errorf("func %s: type %s mismatch with %s", name, fnType.typeName, typeName)
+		}
+		funcPtrContainer := &entry
+		return reflect.NewAt(typ, unsafe.Pointer(&funcPtrContainer)).Elem(), nil
+	}
+	inSize, size := funcArgsSize(typ)
+	if args := cm.funcArgs[name]; args != size && args != inSize {
+		return reflect.Value{}, fmt.Errorf("func %s: args size %d mismatch with %s args size %d", name, args, typ.String(), size)
+	}
+	funcPtrContainer := &entry
+	return reflect.NewAt(typ, unsafe.Pointer(&funcPtrContainer)).Elem(), nil
+}
+
+// LookupFunc looks up the function name in module and returns it as a func value of its own type, like LookupVar.
+// the type of function is read from export data of objs, and its type symbol must be in module or host
+func (cm *CodeModule) LookupFunc(name string) (interface{}, error) {
+	if _, ok := cm.Syms[name]; !ok {
+		return nil, fmt.Errorf("func %s: not found in module", name)
+	}
+	fnType, ok := cm.funcTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("func %s: type is not read from export data", name)
+	}
+	if fnType.typ == 0 || fnType.typ == constants.InvalidHandleValue {
+		return nil, fmt.Errorf("func %s: type %s not resolved", name, fnType.typeName)
+	}
+	fn, err := cm.funcValue(name, toReflectType((*_type)(adduintptr(fnType.typ, 0))))
+	if err != nil {
+		return nil, err
+	}
+	return fn.Interface(), nil
+}
+
+// Var looks up the global variable name in module and stores its address into ptr, ptr must be a pointer to a pointer variable
+func (cm *CodeModule) Var(name string, ptr interface{}) error {
+	ptrValue := reflect.ValueOf(ptr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.IsNil() || ptrValue.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("var %s: need a non-nil pointer to a pointer variable, got %T", name, ptr)
+	}
+	v, ok := cm.vars[name]
+	if !ok {
+		return fmt.Errorf("var %s: not found in module", name)
+	}
+	typ := ptrValue.Elem().Type().Elem()
+	typeName := constants.TypePrefix + resolveTypeName(rtypeOf(typ))
+	if v.typeName != typeName {
+		return fmt.Errorf("var %s: type %s mismatch with %s", name, v.typeName, typeName)
+	}
+	ptrValue.Elem().Set(reflect.NewAt(typ, adduintptr(v.addr, 0)))
+	return nil
+}
+
+// LookupVar looks up the global variable name in module and returns a pointer to it with its own type
+func (cm *CodeModule) LookupVar(name string) (interface{}, error) {
+	v, ok := cm.vars[name]
+	if !ok {
+		return nil, fmt.Errorf("var %s: not found in module", name)
+	}
+	if v.typ == 0 || v.typ == constants.InvalidHandleValue {
+		return nil, fmt.Errorf("var %s: type %s not resolved", name, v.typeName)
+	}
+	typ := toReflectType((*_type)(adduintptr(v.typ, 0)))
+	return reflect.NewAt(typ, adduintptr(v.addr, 0)).Interface(), nil
+}
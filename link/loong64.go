@@ -0,0 +1,168 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+// sys.ArchLoong64 is not defined by old toolchains, loong64 is matched by name
+const archNameLOONG64 = "loong64"
+
+const (
+	loong64OpcodePCADDU12I = 0x1C000000
+	loong64OpcodeLU12IW    = 0x14000000
+	loong64OpcodeORI       = 0x03800000
+	loong64OpcodeLDD       = 0x28C00000
+	loong64OpcodeB         = 0x50000000
+	loong64RegisterMask    = 0x1F
+
+	loong64Si20Mask   = 0x01FFFFE0
+	loong64Si12Mask   = 0x003FFC00
+	loong64Offs26Mask = 0x03FFFFFF
+)
+
+//go:inline
+func isLOONG64(archName string) bool {
+	return archName == archNameLOONG64
+}
+
+func loong64Si20(imm int64) uint32 {
+	return uint32(imm) & 0xFFFFF << 5
+}
+
+func loong64Si12(imm int64) uint32 {
+	return uint32(imm) & 0xFFF << 10
+}
+
+// loong64Offs26 encodes offset into the immediate of a B or BL, it reaches ±128MB
+func loong64Offs26(offset int64) (uint32, bool) {
+	if offset < -1<<27 || offset >= 1<<27 || offset&3 != 0 {
+		return 0, false
+	}
+	imm := uint32(offset >> 2)
+	return (imm&0xFFFF)<<10 | imm>>16&0x3FF, true
+}
+
+// loong64B creates a B to offset
+func loong64B(offset int) (uint32, error) {
+	imm, ok := loong64Offs26(int64(offset))
+	if !ok {
+		return 0, fmt.Errorf("jump offset %#x is out of range of B", offset)
+	}
+	return imm | loong64OpcodeB, nil
+}
+
+// loong64PageOffset returns the immediate of a PCALAU12I at pc, the low 12 bits of address are sign extended
+// by the following instruction, so the page of address is rounded
+func loong64PageOffset(addr, pc uintptr) (int64, bool) {
+	high := (int64(addr+0x800)&^0xFFF - int64(pc)&^0xFFF) >> 12
+	return high, high >= -1<<19 && high < 1<<19
+}
+
+// relocateCALLLOONG64 relocates a BL, a call which overflows jumps to the veneer of its target
+func (linker *Linker) relocateCALLLOONG64(symName string, symAddr uintptr, loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	if imm, ok := loong64Offs26(int64(symAddr) - int64(segment.codeBase+loc.Offset)); ok {
+		byteOrder.PutUint32(code, byteOrder.Uint32(code)&^loong64Offs26Mask|imm)
+		return nil
+	}
+	veneerOffset, _, err := linker.veneer(segment, loc, symAddr)
+	if err != nil {
+		return fmt.Errorf("relocate %s: call to %s overflows, %v", symName, loc.SymName, err)
+	}
+	linker.notifyFarAddress(symName, loc, symAddr, veneerOffset, 0)
+	imm, ok := loong64Offs26(int64(veneerOffset - loc.Offset))
+	if !ok {
+		return fmt.Errorf("relocate %s: call to %s overflows, its veneer at %#x is out of range of BL", symName, loc.SymName, veneerOffset)
+	}
+	byteOrder.PutUint32(code, byteOrder.Uint32(code)&^loong64Offs26Mask|imm)
+	return nil
+}
+
+// relocateADDRLOONG64 relocates a PCALAU12I and its following ADDI or load/store instruction,
+// the low 12 bits don't depend on pc, so the second instruction is relocated on its own.
+// an overflowed PCALAU12I jumps to its epilogue, which loads the address into the register of PCALAU12I
+// and executes the second instruction with zero offset:
+//
+//	PCADDU12I rd, 0
+//	LD.D rd, rd, address-epilogue
+//	ADDI or load/store instruction
+//	B back
+//	address (aligned to 8)
+func (linker *Linker) relocateADDRLOONG64(symName string, symAddr uintptr, loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	if loc.Type == reloctype.R_LOONG64_ADDR_LO {
+		byteOrder.PutUint32(code, byteOrder.Uint32(code)&^loong64Si12Mask|loong64Si12(int64(symAddr)))
+		return nil
+	}
+	if high, ok := loong64PageOffset(symAddr, uintptr(segment.codeBase+loc.Offset)); ok {
+		byteOrder.PutUint32(code, byteOrder.Uint32(code)&^loong64Si20Mask|loong64Si20(high))
+		return nil
+	}
+	if loc.Epilogue.Size == 0 {
+		return fmt.Errorf("relocate %s: %s to %s overflows, but it has no epilogue", symName, reloctype.RelocTypeString(loc.Type), loc.SymName)
+	}
+	epilogueOffset := loc.Epilogue.Offset
+	linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, 0)
+	jump, err := loong64B(epilogueOffset - loc.Offset)
+	if err != nil {
+		return fmt.Errorf("relocate %s: %s to %s overflows, %v", symName, reloctype.RelocTypeString(loc.Type), loc.SymName, err)
+	}
+	back, err := loong64B(loc.Offset + 8 - (epilogueOffset + 12))
+	if err != nil {
+		return fmt.Errorf("relocate %s: %s to %s overflows, %v", symName, reloctype.RelocTypeString(loc.Type), loc.SymName, err)
+	}
+	rd := byteOrder.Uint32(code) & loong64RegisterMask
+	second := byteOrder.Uint32(code[4:]) &^ loong64Si12Mask
+	addressOffset := alignof(epilogueOffset+16, 8)
+	epilogue := segment.codeByte[epilogueOffset:]
+	byteOrder.PutUint32(epilogue, loong64OpcodePCADDU12I|rd)
+	byteOrder.PutUint32(epilogue[4:], loong64OpcodeLDD|loong64Si12(int64(addressOffset-epilogueOffset))|rd<<5|rd)
+	byteOrder.PutUint32(epilogue[8:], second)
+	byteOrder.PutUint32(epilogue[12:], back)
+	putAddress(byteOrder, segment.codeByte[addressOffset:], uint64(symAddr))
+	byteOrder.PutUint32(code, jump)
+	return nil
+}
+
+// relocateTLSLOONG64 relocates the offset of a TLS symbol from TP, an initial-exec PCALAU12I + LD.D pair is relaxed to
+// a local-exec LU12I.W + ORI pair. runtime.tls_g is the only TLS symbol of go, the go linker places it at
+// the beginning of TLS block and relocates it with its addend only
+func (linker *Linker) relocateTLSLOONG64(loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	offset := int64(loc.Add)
+	if offset < -1<<31 || offset >= 1<<31 {
+		return fmt.Errorf("TLS offset %#x of %s does not fit in 32 bits", loc.Add, loc.SymName)
+	}
+	inst := byteOrder.Uint32(code)
+	switch loc.Type {
+	case reloctype.R_LOONG64_TLS_LE_HI:
+		inst = inst&^loong64Si20Mask | loong64Si20(offset>>12)
+	case reloctype.R_LOONG64_TLS_LE_LO:
+		inst = inst&^loong64Si12Mask | loong64Si12(offset)
+	case reloctype.R_LOONG64_TLS_IE_HI:
+		// keep rd of PCALAU12I
+		inst = loong64OpcodeLU12IW | inst&loong64RegisterMask | loong64Si20(offset>>12)
+	case reloctype.R_LOONG64_TLS_IE_LO:
+		// keep rd and rj of LD.D
+		inst = loong64OpcodeORI | inst&(loong64RegisterMask<<5|loong64RegisterMask) | loong64Si12(offset)
+	}
+	byteOrder.PutUint32(code, inst)
+	return nil
+}
+
+func createLOONG64Nops(size int) []byte {
+	if size%len(loong64NOPCode) != 0 {
+		panic(fmt.Sprintf("can't make nop instruction if padding is not multiple of %d, got %d", len(loong64NOPCode), size))
+	}
+	nops := make([]byte, size)
+	for i := 0; i < size; i += len(loong64NOPCode) {
+		copy(nops[i:], loong64NOPCode)
+	}
+	return nops
+}
@@ -0,0 +1,271 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package link
+
+import (
+	"testing"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+const (
+	loong64OpcodeBL        = 0x54000000
+	loong64OpcodeJIRL      = 0x4C000000
+	loong64OpcodePCALAU12I = 0x1A000000
+	loong64Opcode6Mask     = 0xFC000000 // B, BL and JIRL
+	loong64Opcode7Mask     = 0xFE000000 // LU12I.W, PCALAU12I and PCADDU12I
+	loong64Opcode10Mask    = 0xFFC00000 // ORI, ADDI.D and loads/stores
+	loong64RegisterR30     = 30
+)
+
+var loong64TestFiles = map[string]string{
+	"p.go": `package la64test
+
+var counter int
+var table [4096]int64
+
+func tlsLoad() int64
+func tlsStore(v int64)
+
+//go:noinline
+func Store(v int) {
+	counter = v
+	table[v&4095] = int64(v)
+}
+
+func Load(v int) int64 {
+	Store(v)
+	tlsStore(int64(v))
+	return table[v&4095] + int64(counter) + tlsLoad()
+}
+`,
+	"p_loong64.s": `#include "textflag.h"
+
+GLOBL runtime·tls_g(SB), TLSBSS, $8
+
+TEXT ·tlsLoad(SB), NOSPLIT|NOFRAME, $0-8
+	MOVV	runtime·tls_g(SB), R4
+	MOVV	R4, ret+0(FP)
+	RET
+
+TEXT ·tlsStore(SB), NOSPLIT|NOFRAME, $0-8
+	MOVV	v+0(FP), R4
+	MOVV	R4, runtime·tls_g(SB)
+	RET
+`,
+}
+
+func loong64Rd(inst uint32) uint32 {
+	return inst & loong64RegisterMask
+}
+
+func loong64Rj(inst uint32) uint32 {
+	return inst >> 5 & loong64RegisterMask
+}
+
+// loong64DecodeSi20 returns the immediate of a LU12I.W, PCALAU12I or PCADDU12I shifted left by 12
+func loong64DecodeSi20(inst uint32) int64 {
+	return int64(int32(inst << 7 &^ 0xFFF))
+}
+
+func loong64DecodeSi12(inst uint32) int64 {
+	return int64(int32(inst<<10) >> 20)
+}
+
+func loong64DecodeUi12(inst uint32) int64 {
+	return int64(inst >> 10 & 0xFFF)
+}
+
+func loong64DecodeOffs26(inst uint32) int64 {
+	return int64(int32((inst&0x3FF<<16|inst>>10&0xFFFF)<<6) >> 4)
+}
+
+// decodeLOONG64Call follows a call to target, through the veneer of its target if target is a veneer
+func decodeLOONG64Call(t *testing.T, cm *CodeModule, target uintptr) crossTarget {
+	off := int(target) - cm.codeBase - cm.veneerOff
+	if cm.veneerLen == 0 || off < 0 || off >= cm.veneerLen {
+		return crossTarget{addr: target, path: "direct"}
+	}
+	byteOrder := cm.arch.ByteOrder
+	veneer := cm.codeByte[cm.veneerOff+off:]
+	pcaddu12i, ld, jirl := byteOrder.Uint32(veneer), byteOrder.Uint32(veneer[4:]), byteOrder.Uint32(veneer[8:])
+	if off%veneerSizeLOONG64 != 0 || pcaddu12i != loong64OpcodePCADDU12I|loong64RegisterR30 ||
+		ld&loong64Opcode10Mask != loong64OpcodeLDD || loong64Rd(ld) != loong64RegisterR30 || loong64Rj(ld) != loong64RegisterR30 ||
+		jirl != loong64OpcodeJIRL|loong64RegisterR30<<5 {
+		t.Errorf("invalid veneer at %#x: %#x %#x %#x", target, pcaddu12i, ld, jirl)
+		return crossTarget{addr: target, path: "invalid veneer"}
+	}
+	return crossTarget{addr: uintptr(getAddress(byteOrder, veneer[loong64DecodeSi12(ld):])), path: "veneer"}
+}
+
+// decodeLOONG64Addr decodes a relocated PCALAU12I at offset and its following instruction,
+// or the epilogue which the overflowed PCALAU12I jumps to
+func decodeLOONG64Addr(t *testing.T, linker *Linker, cm *CodeModule, offset int) crossTarget {
+	byteOrder := cm.arch.ByteOrder
+	code := cm.codeByte[offset:]
+	pc := int64(cm.codeBase + offset)
+	first, second := byteOrder.Uint32(code), byteOrder.Uint32(code[4:])
+	if first&loong64Opcode7Mask == loong64OpcodePCALAU12I {
+		return crossTarget{addr: uintptr(pc&^0xFFF + loong64DecodeSi20(first) + loong64DecodeSi12(second)), path: "direct"}
+	}
+	if first&loong64Opcode6Mask != loong64OpcodeB {
+		t.Errorf("PCALAU12I at %#x is neither relocated nor replaced by a B: %#x", offset, first)
+		return crossTarget{path: "invalid epilogue"}
+	}
+	epilogueOffset := offset + int(loong64DecodeOffs26(first))
+	original := linker.Code[offset:]
+	epilogue := cm.codeByte[epilogueOffset:]
+	pcaddu12i, ld, inst, back := byteOrder.Uint32(epilogue), byteOrder.Uint32(epilogue[4:]), byteOrder.Uint32(epilogue[8:]), byteOrder.Uint32(epilogue[12:])
+	rd := loong64Rd(byteOrder.Uint32(original))
+	addressOffset := epilogueOffset + int(loong64DecodeSi12(ld))
+	if pcaddu12i != loong64OpcodePCADDU12I|rd ||
+		ld&loong64Opcode10Mask != loong64OpcodeLDD || loong64Rd(ld) != rd || loong64Rj(ld) != rd || addressOffset%8 != 0 ||
+		inst != byteOrder.Uint32(original[4:])&^loong64Si12Mask ||
+		back&loong64Opcode6Mask != loong64OpcodeB || epilogueOffset+12+int(loong64DecodeOffs26(back)) != offset+8 {
+		t.Errorf("invalid epilogue of PCALAU12I at %#x: %#x %#x %#x %#x", offset, pcaddu12i, ld, inst, back)
+		return crossTarget{path: "invalid epilogue"}
+	}
+	return crossTarget{addr: uintptr(getAddress(byteOrder, cm.codeByte[addressOffset:])), path: "epilogue"}
+}
+
+// decodeLOONG64TLS decodes the offset from TP loaded by a LU12I.W + ORI pair at offset,
+// an initial-exec PCALAU12I + LD.D pair is relaxed to it
+func decodeLOONG64TLS(t *testing.T, linker *Linker, cm *CodeModule, offset int) crossTarget {
+	byteOrder := cm.arch.ByteOrder
+	code := cm.codeByte[offset:]
+	original := linker.Code[offset:]
+	lu12iw, ori := byteOrder.Uint32(code), byteOrder.Uint32(code[4:])
+	originalSecond := byteOrder.Uint32(original[4:])
+	if lu12iw&loong64Opcode7Mask != loong64OpcodeLU12IW || loong64Rd(lu12iw) != loong64Rd(byteOrder.Uint32(original)) ||
+		ori&loong64Opcode10Mask != loong64OpcodeORI || loong64Rd(ori) != loong64Rd(originalSecond) || loong64Rj(ori) != loong64Rj(originalSecond) {
+		t.Errorf("TLS access at %#x is not relaxed to LU12I.W + ORI: %#x %#x", offset, lu12iw, ori)
+	}
+	return crossTarget{addr: uintptr(loong64DecodeSi20(lu12iw) | loong64DecodeUi12(ori)), path: "tls"}
+}
+
+func decodeLOONG64(t *testing.T, linker *Linker, cm *CodeModule, loc obj.Reloc) crossTarget {
+	byteOrder := cm.arch.ByteOrder
+	inst := byteOrder.Uint32(cm.codeByte[loc.Offset:])
+	switch loc.Type {
+	case reloctype.R_CALLLOONG64:
+		if inst&loong64Opcode6Mask != loong64OpcodeBL {
+			t.Errorf("%s to %s is not a BL: %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, inst)
+		}
+		return decodeLOONG64Call(t, cm, uintptr(int64(cm.codeBase+loc.Offset)+loong64DecodeOffs26(inst)))
+	case reloctype.R_LOONG64_ADDR_HI:
+		if inst&loong64Opcode6Mask == loong64OpcodeB && loc.Offset+int(loong64DecodeOffs26(inst)) != loc.Epilogue.Offset {
+			t.Errorf("%s to %s doesn't jump to its epilogue: %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, inst)
+			return crossTarget{path: "invalid epilogue"}
+		}
+		return decodeLOONG64Addr(t, linker, cm, loc.Offset)
+	case reloctype.R_LOONG64_ADDR_LO:
+		return decodeLOONG64Addr(t, linker, cm, loc.Offset-4)
+	case reloctype.R_LOONG64_TLS_LE_HI, reloctype.R_LOONG64_TLS_IE_HI:
+		return decodeLOONG64TLS(t, linker, cm, loc.Offset)
+	case reloctype.R_LOONG64_TLS_LE_LO, reloctype.R_LOONG64_TLS_IE_LO:
+		return decodeLOONG64TLS(t, linker, cm, loc.Offset-4)
+	}
+	return crossTarget{}
+}
+
+func TestRelocateLOONG64Objs(t *testing.T) {
+	testCases := []struct {
+		name  string
+		flags []string
+		far   bool
+		want  map[int][]string
+	}{
+		{
+			name: "near",
+			want: map[int][]string{
+				reloctype.R_CALLLOONG64:       {"direct"},
+				reloctype.R_LOONG64_ADDR_HI:   {"direct"},
+				reloctype.R_LOONG64_ADDR_LO:   {"direct"},
+				reloctype.R_LOONG64_TLS_LE_HI: {"tls"},
+				reloctype.R_LOONG64_TLS_LE_LO: {"tls"},
+			},
+		},
+		{
+			name: "far",
+			far:  true,
+			want: map[int][]string{
+				reloctype.R_CALLLOONG64:       {"direct", "veneer"},
+				reloctype.R_LOONG64_ADDR_HI:   {"epilogue"},
+				reloctype.R_LOONG64_ADDR_LO:   {"epilogue"},
+				reloctype.R_LOONG64_TLS_LE_HI: {"tls"},
+				reloctype.R_LOONG64_TLS_LE_LO: {"tls"},
+			},
+		},
+		{
+			name:  "initial exec TLS",
+			flags: []string{"-asmflags=-shared"},
+			want: map[int][]string{
+				reloctype.R_LOONG64_TLS_IE_HI: {"tls"},
+				reloctype.R_LOONG64_TLS_IE_LO: {"tls"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			linker := readCrossObjs(t, archNameLOONG64, "la64test", loong64TestFiles, testCase.flags...)
+			cm, symbolMap := relocateCrossObjs(t, linker, testCase.far)
+			paths := checkCrossRelocs(t, linker, cm, symbolMap, decodeLOONG64)
+			for relocType, want := range testCase.want {
+				for _, path := range want {
+					if paths[relocType][path] == 0 {
+						t.Errorf("no %s is relocated through %s, got %v", reloctype.RelocTypeString(relocType), path, paths[relocType])
+					}
+				}
+			}
+		})
+	}
+}
+
+// a BL reaches ±128MB, calls out of its range are only emitted by modules placed far from host,
+// so a BL is relocated alone around the bounds of its range
+func TestRelocateCALLLOONG64(t *testing.T) {
+	if uint64(uintptr(crossFar)) != crossFar {
+		t.Skip("relocations of 64-bit objs are not tested on 32-bit hosts")
+	}
+	testCases := []struct {
+		name   string
+		offset int64
+		path   string
+	}{
+		{name: "near", offset: 0x1000, path: "direct"},
+		{name: "backward", offset: -0x1234, path: "direct"},
+		{name: "last forward", offset: 1<<27 - 4, path: "direct"},
+		{name: "first backward", offset: -1 << 27, path: "direct"},
+		{name: "overflow forward", offset: 1 << 27, path: "veneer"},
+		{name: "overflow backward", offset: -1<<27 - 4, path: "veneer"},
+		{name: "far", offset: int64(crossFar), path: "veneer"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			linker := initLinker()
+			linker.Arch = getArch(archNameLOONG64)
+			loc := obj.Reloc{Offset: 0, Size: 4, Type: reloctype.R_CALLLOONG64, SymName: "host.f"}
+			linker.addVeneer(loc)
+			// BL 0
+			linker.Code = make([]byte, loc.Size)
+			linker.Arch.ByteOrder.PutUint32(linker.Code, loong64OpcodeBL)
+			cm := &CodeModule{arch: linker.Arch}
+			cm.codeBase = crossCodeBase
+			cm.veneerOff = veneerAlign
+			cm.veneerLen = len(linker.Veneers) * veneerSizeLOONG64
+			cm.codeByte = make([]byte, cm.veneerOff+cm.veneerLen)
+			copy(cm.codeByte, linker.Code)
+
+			target := uintptr(crossCodeBase + testCase.offset)
+			if err := linker.relocateCALLLOONG64("test", target, loc, &cm.segment); err != nil {
+				t.Fatal(err)
+			}
+			decoded := decodeLOONG64(t, linker, cm, loc)
+			if decoded.addr != target || decoded.path != testCase.path {
+				t.Errorf("call is relocated to %#x through %s, want %#x through %s", decoded.addr, decoded.path, target, testCase.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package link
+
+import (
+	"cmd/objfile/sys"
+	"runtime"
+
+	"github.com/pkujhd/goloader/mmap"
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+// on linux/arm64 a BL reaches ±128MB and an ADRP reaches ±4GB, code segments are placed near host text,
+// and data segments near host image, so that code is within the range of ADRP to data and host.
+// on linux/riscv64 an AUIPC reaches ±2GB, both segments are placed within 1GB of host image.
+// on linux/loong64 a BL reaches ±128MB like arm64, but a PCALAU12I only reaches ±2GB
+const (
+	nearCodeDistanceARM64   = 1<<27 - 1<<12
+	nearDataDistanceARM64   = 1<<32 - 1<<28
+	nearDistanceRISCV64     = 1<<30 - 1<<20
+	nearDataDistanceLOONG64 = 1<<31 - 1<<28
+)
+
+// isMmapNearHost reports whether segments are placed near host by mmap hints
+func isMmapNearHost(archName string) bool {
+	return (archName == sys.ArchARM64.Name || isRISCV64(archName) || isLOONG64(archName)) && runtime.GOOS == "linux" && runtime.GOARCH == archName
+}
+
+// nearHostCode returns the range which code segments are placed near, it is empty if segments are placed anywhere
+func nearHostCode() mmap.Near {
+	if !isMmapNearHost(runtime.GOARCH) || firstmoduledata == nil {
+		return mmap.Near{}
+	}
+	if isRISCV64(runtime.GOARCH) {
+		return mmap.Near{Start: firstmoduledata.text, End: firstmoduledata.end, Distance: nearDistanceRISCV64}
+	}
+	return mmap.Near{Start: firstmoduledata.text, End: firstmoduledata.etext, Distance: nearCodeDistanceARM64}
+}
+
+// nearHostData returns the range which data segments are placed near, it is empty if segments are placed anywhere
+func nearHostData() mmap.Near {
+	if !isMmapNearHost(runtime.GOARCH) || firstmoduledata == nil {
+		return mmap.Near{}
+	}
+	if isRISCV64(runtime.GOARCH) {
+		return mmap.Near{Start: firstmoduledata.text, End: firstmoduledata.end, Distance: nearDistanceRISCV64}
+	}
+	if isLOONG64(runtime.GOARCH) {
+		return mmap.Near{Start: firstmoduledata.text, End: firstmoduledata.end, Distance: nearDataDistanceLOONG64}
+	}
+	return mmap.Near{Start: firstmoduledata.text, End: firstmoduledata.end, Distance: nearDataDistanceARM64}
+}
+
+func MmapCodeNear(size int) ([]byte, error) {
+	return mmap.MmapCodeNear(size, nearHostCode())
+}
+
+func MmapNear(size int) ([]byte, error) {
+	return mmap.MmapNear(size, nearHostCode())
+}
+
+func MmapDataNear(size int) ([]byte, error) {
+	return mmap.MmapDataNear(size, nearHostData())
+}
+
+// isNearHost reports whether the segments of module are placed near host
+func (cm *CodeModule) isNearHost() bool {
+	return nearHostCode().Contains(uintptr(cm.codeBase), uintptr(len(cm.codeByte))) &&
+		nearHostData().Contains(uintptr(cm.dataBase), uintptr(len(cm.dataByte)))
+}
+
+// isNearReloc reports whether reloc to a symbol of objs has no epilogue because linker is read by an older version
+// which assumes segments are placed near host, the target is either in the module or resolved to host
+func (linker *Linker) isNearReloc(reloc obj.Reloc) bool {
+	if !linker.NearHost || isStringTypeName(reloc.SymName) {
+		return false
+	}
+	if _, ok := linker.ObjSymbolMap[reloc.SymName]; !ok {
+		return false
+	}
+	switch reloc.Type {
+	case reloctype.R_ADDRARM64, reloctype.R_ARM64_PCREL_LDST8, reloctype.R_ARM64_PCREL_LDST16,
+		reloctype.R_ARM64_PCREL_LDST32, reloctype.R_ARM64_PCREL_LDST64,
+		reloctype.R_RISCV_PCREL_ITYPE, reloctype.R_RISCV_PCREL_STYPE,
+		reloctype.R_LOONG64_ADDR_HI:
+		return true
+	}
+	return false
+}
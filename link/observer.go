@@ -0,0 +1,158 @@
+package link
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+// Phase is a step of reading and loading objs
+type Phase int
+
+const (
+	PhaseRead        Phase = iota // read an obj file
+	PhaseResolve                  // resolve symbols of packages
+	PhaseLayout                   // lay symbols out in segments, and assign addresses to them when loading
+	PhaseRelocate                 // relocate code and data
+	PhaseBuildModule              // build and register moduledata, typelinks and itabs
+	PhaseInitialize               // run init functions of packages
+	PhaseVerify                   // verify relocated code and pc tables, only if Linker.Verify is set
+)
+
+var phaseNames = []string{"read", "resolve", "layout", "relocate", "build module", "initialize", "verify"}
+
+func (phase Phase) String() string {
+	if phase >= 0 && int(phase) < len(phaseNames) {
+		return phaseNames[phase]
+	}
+	return fmt.Sprintf("phase(%d)", int(phase))
+}
+
+// EventKind is the kind of an Event
+type EventKind int
+
+const (
+	EventPhaseStart EventKind = iota // a phase starts
+	EventPhaseEnd                    // a phase ends, Duration and Err are set
+	EventFarAddress                  // a relocation overflows, the target is reached by an epilogue or a data slot
+	EventGOTSlot                     // a slot in data segment is allocated for the address of Target
+	EventFakeItab                    // a fake itab is added for SymName and interface Target
+)
+
+var eventKindNames = []string{"phase start", "phase end", "far address", "got slot", "fake itab"}
+
+func (kind EventKind) String() string {
+	if kind >= 0 && int(kind) < len(eventKindNames) {
+		return eventKindNames[kind]
+	}
+	return fmt.Sprintf("event(%d)", int(kind))
+}
+
+// Event is sent to an Observer, fields which are not related to its kind are zero
+type Event struct {
+	Kind      EventKind
+	Phase     Phase
+	File      string        // obj file of PhaseRead
+	Duration  time.Duration // duration of the phase of EventPhaseEnd
+	Err       error         // error of the phase of EventPhaseEnd
+	SymName   string        // symbol which holds the relocation, or the type of a fake itab
+	Target    string        // symbol which is relocated to, or the interface of a fake itab
+	RelocType string        // type of the relocation
+	Op        string        // instruction of the relocation on x86
+	Offset    int           // offset of the relocation in code segment, not in the function SymName
+	Epilogue  int           // offset of the epilogue in code segment, -1 if no epilogue is used
+	Addr      uintptr       // address of the target
+	Slot      uintptr       // address of the slot holding Addr, 0 if no slot is used
+}
+
+func (e Event) String() string {
+	switch e.Kind {
+	case EventPhaseStart:
+		if e.File != "" {
+			return fmt.Sprintf("%s start: %s", e.Phase, e.File)
+		}
+		return fmt.Sprintf("%s start", e.Phase)
+	case EventPhaseEnd:
+		s := fmt.Sprintf("%s end (%v)", e.Phase, e.Duration)
+		if e.File != "" {
+			s += ": " + e.File
+		}
+		if e.Err != nil {
+			s += fmt.Sprintf(" error: %v", e.Err)
+		}
+		return s
+	case EventFarAddress:
+		return fmt.Sprintf("far address: %s at code+%#x %s %s -> %s(%#x) epilogue:%#x slot:%#x", e.SymName, e.Offset, e.RelocType, e.Op, e.Target, e.Addr, e.Epilogue, e.Slot)
+	case EventGOTSlot:
+		return fmt.Sprintf("got slot: %#x -> %s(%#x)", e.Slot, e.Target, e.Addr)
+	case EventFakeItab:
+		return fmt.Sprintf("fake itab: %s, %s", e.Target, e.SymName)
+	}
+	return e.Kind.String()
+}
+
+// Observer receives events of reading and loading a Linker, for logging, counting or tracing.
+// it is called synchronously from the goroutine which reads or loads
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc is an Observer of a function
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// WriterObserver returns an Observer which writes every event as a line into writer
+func WriterObserver(writer io.Writer) Observer {
+	return ObserverFunc(func(event Event) {
+		fmt.Fprintln(writer, event.String())
+	})
+}
+
+func notify(observer Observer, event Event) {
+	if observer != nil {
+		observer.Observe(event)
+	}
+}
+
+func (linker *Linker) notify(event Event) {
+	notify(linker.Observer, event)
+}
+
+// phase notifies the start of phase, the returned function notifies its end
+func (linker *Linker) phase(phase Phase, file string) func(err error) {
+	if linker.Observer == nil {
+		return func(err error) {}
+	}
+	start := time.Now()
+	linker.notify(Event{Kind: EventPhaseStart, Phase: phase, File: file})
+	return func(err error) {
+		linker.notify(Event{Kind: EventPhaseEnd, Phase: phase, File: file, Duration: time.Since(start), Err: err})
+	}
+}
+
+func (linker *Linker) notifyGOTSlot(target string, addr, slot uintptr) {
+	linker.notify(Event{Kind: EventGOTSlot, Target: target, Addr: addr, Slot: slot, Epilogue: -1})
+}
+
+func (linker *Linker) notifyFarAddress(symName string, loc obj.Reloc, addr uintptr, epilogue int, slot uintptr) {
+	if linker.Observer == nil {
+		return
+	}
+	linker.notify(Event{
+		Kind:      EventFarAddress,
+		SymName:   symName,
+		Target:    loc.SymName,
+		RelocType: reloctype.RelocTypeString(loc.Type),
+		Op:        obj.GetOpName(loc.Op),
+		Offset:    loc.Offset,
+		Epilogue:  epilogue,
+		Addr:      addr,
+		Slot:      slot,
+	})
+}
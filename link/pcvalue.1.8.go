@@ -10,6 +10,9 @@ import (
 func adaptPCFile(linker *Linker, symbol *obj.ObjSymbol) {
 	// golang version <= 1.15 PCFile need rewrite, PCFile (pc, val), val only adapte symbol.Func.File
 	p := symbol.Func.PCFile
+	if len(p) == 0 {
+		return
+	}
 	pcfile := make([]byte, 0)
 	pc := uintptr(0)
 	lastpc := uintptr(0)
@@ -79,16 +79,21 @@ func updateLastPCValue(pcVals *[]byte, nval int32, npc, pcQuantum uintptr) {
 	*pcVals = npcVals
 }
 
+// getPCQuantum returns the unit of pc deltas in pcvalue tables, it is the minimum length of an instruction
+func getPCQuantum(arch *sys.Arch) uintptr {
+	if arch.Family == sys.ARM64 || isRISCV64(arch.Name) || isLOONG64(arch.Name) {
+		return uintptr(arch.MinLC)
+	}
+	return 1
+}
+
 func patchPCValues(linker *Linker, pcVals *[]byte, reloc obj.Reloc) {
 	// Use the pcvalue at the offset of the reloc for the entire of that reloc's epilogue.
 	// This ensures that if the code is pre-empted or the stack unwound while we're inside the epilogue, the runtime behaves correctly
 	if len(*pcVals) == 0 {
 		return
 	}
-	var pcQuantum uintptr = 1
-	if linker.Arch.Family == sys.ARM64 {
-		pcQuantum = 4
-	}
+	pcQuantum := getPCQuantum(linker.Arch)
 	val, startPC := pcValue(*pcVals, uintptr(reloc.Offset))
 	if startPC == constants.InvalidHandleValue && val == -1 {
 		panic(fmt.Sprintf("couldn't interpret pcvalue data with pc offset: %d", reloc.Offset))
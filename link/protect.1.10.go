@@ -0,0 +1,6 @@
+//go:build go1.10
+// +build go1.10
+
+package link
+
+const itabReadOnly = true
@@ -0,0 +1,7 @@
+//go:build go1.8 && !go1.10
+// +build go1.8,!go1.10
+
+package link
+
+// itabAdd writes the link and inhash of itab
+const itabReadOnly = false
@@ -0,0 +1,59 @@
+package link
+
+import (
+	"os"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/mmap"
+)
+
+// layoutReadOnlyData pads the data segment, so that type data (with itab data if itabs are never written)
+// and pclntab start and end at page boundaries, and can be made read-only after buildModule.
+// the padding only depends on linker, the offsets of symbols stay the same when a Linker is loaded again.
+// a packed layout has no padding, it is used by modules in an Arena which are never protected
+func layoutReadOnlyData(linker *Linker, codeModule *CodeModule) {
+	dataSeg := &codeModule.segment.dataSeg
+	if linker.PackedLayout {
+		dataSeg.dataLen = len(linker.Data)
+		dataSeg.noPtrTypeDataLen = len(linker.NoPtrTypeData)
+		dataSeg.noPtrDataLen = len(linker.NoPtrData)
+		dataSeg.pclntabLen = getPclntabLength(linker, codeModule)
+		return
+	}
+	dataSeg.dataLen = alignof(len(linker.Data), constants.PageSize)
+	readOnlyLen := len(linker.NoPtrTypeData)
+	if itabReadOnly {
+		readOnlyLen += len(linker.NoPtrItabData)
+	}
+	dataSeg.noPtrTypeDataLen = alignof(dataSeg.dataLen+readOnlyLen, constants.PageSize) - dataSeg.dataLen - readOnlyLen + len(linker.NoPtrTypeData)
+	noPtrDataOff := dataSeg.dataLen + dataSeg.noPtrTypeDataLen + dataSeg.noPtrItabDataLen
+	dataSeg.noPtrDataLen = alignof(noPtrDataOff+len(linker.NoPtrData), constants.PageSize) - noPtrDataOff
+	dataSeg.pclntabLen = alignof(getPclntabLength(linker, codeModule), constants.PageSize)
+}
+
+// protect makes code read+execute, and type data, itab data and pclntab read-only,
+// only the pages which are entirely inside these sections are protected, modules in an Arena are not protected
+func (cm *CodeModule) protect() error {
+	if cm.arena != nil {
+		return nil
+	}
+	if err := mmap.ProtectCode(cm.codeByte); err != nil {
+		return err
+	}
+	dataSeg := &cm.segment.dataSeg
+	typeEnd := dataSeg.dataLen + dataSeg.noPtrTypeDataLen
+	if itabReadOnly {
+		typeEnd += dataSeg.noPtrItabDataLen
+	}
+	pclntabOff := dataSeg.dataLen + dataSeg.noPtrTypeDataLen + dataSeg.noPtrItabDataLen + dataSeg.noPtrDataLen
+	pageSize := os.Getpagesize()
+	for _, section := range [][2]int{{dataSeg.dataLen, typeEnd}, {pclntabOff, pclntabOff + dataSeg.pclntabLen}} {
+		start, end := alignof(section[0], pageSize), section[1]&^(pageSize-1)
+		if start < end {
+			if err := mmap.ProtectReadOnly(dataSeg.dataByte[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
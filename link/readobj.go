@@ -29,8 +29,10 @@ func (linker *Linker) readObj(file, pkgPath string) error {
 	}
 	if linker.Arch != nil && linker.Arch.Name != pkg.Arch {
 		return fmt.Errorf("read obj error: Arch %s != Arch %s", linker.Arch.Name, pkg.Arch)
-	} else {
+	} else if linker.Arch == nil {
 		linker.Arch = getArch(pkg.Arch)
+		// segments may be mapped anywhere once the low address space is exhausted, even after objs are read
+		linker.FarAddressEpilogues = true
 	}
 
 	pkg.AddCgoFuncs(linker.CgoFuncs)
@@ -39,11 +41,21 @@ func (linker *Linker) readObj(file, pkgPath string) error {
 }
 
 func (linker *Linker) resolveSymbols() {
-	for _, pkg := range linker.Packages {
-		pkg.AddSymIndex(linker.CgoFuncs)
+	linker.resolvePkgSymbols(nil)
+}
+
+// resolvePkgSymbols resolves symbols of packages, resolved is called with every package before its symbols are dropped
+func (linker *Linker) resolvePkgSymbols(resolved func(pkg *obj.Pkg)) {
+	pkgPaths := sortedKeys(linker.Packages)
+	for _, pkgPath := range pkgPaths {
+		linker.Packages[pkgPath].AddSymIndex(linker.CgoFuncs)
 	}
-	for _, pkg := range linker.Packages {
+	for _, pkgPath := range pkgPaths {
+		pkg := linker.Packages[pkgPath]
 		pkg.ResolveSymbols(linker.Packages, linker.ObjSymbolMap, linker.CUOffset)
+		if resolved != nil && pkg.Syms != nil {
+			resolved(pkg)
+		}
 		pkg.GoArchive = nil
 		pkg.Syms = nil
 		linker.addFiles(pkg.CUFiles)
@@ -54,28 +66,40 @@ func (linker *Linker) resolveSymbols() {
 }
 
 func ReadObj(file, pkgPath string) (*Linker, error) {
-	linker := initLinker()
-	if err := linker.readObj(file, pkgPath); err != nil {
-		return nil, err
-	}
-	linker.resolveSymbols()
-	linker.initPcHeader()
-	if err := linker.addSymbols(); err != nil {
-		return nil, err
-	}
-	return linker, nil
+	return ReadObjsWithObserver([]string{file}, []string{pkgPath}, nil, nil)
 }
 
 func ReadObjs(files []string, pkgPaths []string) (*Linker, error) {
+	return ReadObjsWithRoots(files, pkgPaths, nil)
+}
+
+// ReadObjsWithRoots reads objs like ReadObjs, but only links roots, init functions of packages
+// and the symbols reachable from them, unreachable functions, types and data are dropped.
+// a nil roots links every function like ReadObjs
+func ReadObjsWithRoots(files []string, pkgPaths []string, roots []string) (*Linker, error) {
+	return ReadObjsWithObserver(files, pkgPaths, roots, nil)
+}
+
+// ReadObjsWithObserver reads objs like ReadObjsWithRoots, and sends events of every phase to observer,
+// observer is kept in the returned Linker and also receives the events of Load
+func ReadObjsWithObserver(files []string, pkgPaths []string, roots []string, observer Observer) (*Linker, error) {
 	linker := initLinker()
+	linker.Observer = observer
 	for i, file := range files {
-		if err := linker.readObj(file, pkgPaths[i]); err != nil {
+		end := linker.phase(PhaseRead, file)
+		err := linker.readObj(file, pkgPaths[i])
+		if end(err); err != nil {
 			return nil, err
 		}
+		linker.addFuncTypes(file, pkgPaths[i])
 	}
+	end := linker.phase(PhaseResolve, constants.EmptyString)
 	linker.resolveSymbols()
+	end(nil)
 	linker.initPcHeader()
-	if err := linker.addSymbols(); err != nil {
+	end = linker.phase(PhaseLayout, constants.EmptyString)
+	err := linker.addSymbols(roots)
+	if end(err); err != nil {
 		return nil, err
 	}
 	return linker, nil
@@ -104,8 +128,8 @@ func ReadDependPackages(linker *Linker, files, pkgPaths []string, symbolNames []
 		}
 	}
 
-	for _, pkg := range linker.Packages {
-		name := getInitFuncName(pkg.PkgPath)
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		name := getInitFuncName(pkgPath)
 		if _, ok := linker.ObjSymbolMap[name]; ok {
 			if symbol, ok := linker.SymMap[name]; !ok || symbol.Offset == constants.InvalidOffset {
 				if !isCompleteInitialization(linker, name, symPtr) {
@@ -121,7 +145,7 @@ func ReadDependPackages(linker *Linker, files, pkgPaths []string, symbolNames []
 	unimplementedTypes := checkUnimplementedInterface(linker, symPtr)
 	if unimplementedTypes != nil {
 		unresolvedSymbols := make([]string, len(unimplementedTypes))
-		for name := range unimplementedTypes {
+		for _, name := range sortedKeys(unimplementedTypes) {
 			unresolvedSymbols = append(unresolvedSymbols, name)
 			delete(symPtr, name)
 		}
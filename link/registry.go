@@ -0,0 +1,101 @@
+package link
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// moduleInfo is the identity of a module, it is recorded when module is loaded
+type moduleInfo struct {
+	name     string
+	loadTime time.Time
+	packages []string
+}
+
+// ModuleInfo describes a loaded module, address ranges are [Start, End)
+type ModuleInfo struct {
+	Name      string
+	LoadTime  time.Time
+	CodeStart uintptr
+	CodeEnd   uintptr
+	DataStart uintptr
+	DataEnd   uintptr
+	Packages  []string
+}
+
+// registry holds the loaded modules in load order
+var (
+	registry     []*CodeModule
+	registryLock sync.RWMutex
+)
+
+// setModuleInfo records the identity of module, the name defaults to the packages of linker
+func (linker *Linker) setModuleInfo(codeModule *CodeModule) {
+	codeModule.packages = sortedKeys(linker.Packages)
+	codeModule.name = linker.Name
+	if codeModule.name == "" {
+		codeModule.name = strings.Join(codeModule.packages, ",")
+	}
+	codeModule.loadTime = time.Now()
+}
+
+func registerModule(codeModule *CodeModule) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, codeModule)
+}
+
+func unregisterModule(codeModule *CodeModule) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for index, module := range registry {
+		if module == codeModule {
+			copy(registry[index:], registry[index+1:])
+			registry[len(registry)-1] = nil
+			registry = registry[:len(registry)-1]
+			return
+		}
+	}
+}
+
+// Info returns the name, load time, address ranges and packages of module, address ranges are 0 after it is unloaded
+func (cm *CodeModule) Info() ModuleInfo {
+	info := ModuleInfo{
+		Name:     cm.name,
+		LoadTime: cm.loadTime,
+		Packages: append([]string{}, cm.packages...),
+	}
+	if cm.codeByte != nil {
+		info.CodeStart = uintptr(cm.codeBase)
+		info.CodeEnd = uintptr(cm.codeBase + len(cm.codeByte))
+	}
+	if cm.dataByte != nil {
+		info.DataStart = uintptr(cm.dataBase)
+		info.DataEnd = uintptr(cm.dataBase + len(cm.dataByte))
+	}
+	return info
+}
+
+// Modules returns the loaded modules in load order
+func Modules() []ModuleInfo {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	infos := make([]ModuleInfo, len(registry))
+	for index, module := range registry {
+		infos[index] = module.Info()
+	}
+	return infos
+}
+
+// ModuleForPC returns the loaded module whose code segment contains pc, or nil if pc is not in any module
+func ModuleForPC(pc uintptr) *CodeModule {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	for _, module := range registry {
+		if pc >= uintptr(module.codeBase) && pc < uintptr(module.codeBase+len(module.codeByte)) {
+			return module
+		}
+	}
+	return nil
+}
@@ -15,6 +15,8 @@ const (
 	maxExtraCodeSize_ADDRARM64        = 24
 	maxExtraCodeSize_CALLARM64        = 16
 	maxExtraCodeSize_ARM64_PCREL_LDST = 24
+	maxExtraCodeSize_RISCV64_PCREL    = 32
+	maxExtraCodeSize_LOONG64_ADDR     = 32
 	maxExtraCodeSize_PCRELxMOV        = 18
 	maxExtraCodeSize_PCRELxCMPL       = 22
 	maxExtraCodeSize_PCRELxCALL       = 11
@@ -27,10 +29,24 @@ func expandFunc(linker *Linker, objsym *obj.ObjSymbol, symbol *obj.Sym) {
 	// instructions at the end in the case of a 32 bit overflow. These epilogue PCs need to be added to
 	// the PCData, PCLine, PCFile, PCSP etc.
 	for i, reloc := range objsym.Reloc {
-		// on linux/amd64, mmap force return < 32bit address,
-		// doesn't need to add extra instructions except relocate symbol is a string.
-		// because string is dynamic allocate in a far address
-		if !isMmapInLowAddress(linker.Arch.Name) || isStringTypeName(reloc.SymName) {
+		// a GOT slot is shared by every relocation to the same target
+		if reloc.Type == reloctype.R_GOTPCREL || reloc.Type == reloctype.R_ARM64_GOTPCREL {
+			linker.addGOTSlot(reloc)
+			continue
+		}
+		// on linux/amd64, mmap force return < 32bit address, the epilogues are only used by relocations to strings,
+		// because string is dynamic allocate in a far address.
+		// once the low address space is exhausted, segments are mapped anywhere and every relocation needs them
+		if linker.FarAddressEpilogues || isStringTypeName(reloc.SymName) {
+			// a call is relocated to the veneer of its target instead of an epilogue
+			if useVeneer(linker.Arch, reloc) {
+				linker.addVeneer(reloc)
+				continue
+			}
+			// a Linker of an older version has no epilogues for an ADRP, AUIPC or PCALAU12I to the module or host
+			if linker.isNearReloc(reloc) {
+				continue
+			}
 			epilogue := &(objsym.Reloc[i].Epilogue)
 			epilogue.Offset = len(linker.Code) - symbol.Offset
 			switch reloc.Type {
@@ -40,6 +56,10 @@ func expandFunc(linker *Linker, objsym *obj.ObjSymbol, symbol *obj.Sym) {
 				epilogue.Size = maxExtraCodeSize_CALLARM64
 			case reloctype.R_ARM64_PCREL_LDST8, reloctype.R_ARM64_PCREL_LDST16, reloctype.R_ARM64_PCREL_LDST32, reloctype.R_ARM64_PCREL_LDST64:
 				epilogue.Size = maxExtraCodeSize_ARM64_PCREL_LDST
+			case reloctype.R_RISCV_PCREL_ITYPE, reloctype.R_RISCV_PCREL_STYPE:
+				epilogue.Size = maxExtraCodeSize_RISCV64_PCREL
+			case reloctype.R_LOONG64_ADDR_HI:
+				epilogue.Size = maxExtraCodeSize_LOONG64_ADDR
 			case reloctype.R_CALL:
 				epilogue.Size = maxExtraCodeSize_CALL
 				linker.ExtraData += constants.PtrSize
@@ -59,8 +79,6 @@ func expandFunc(linker *Linker, objsym *obj.ObjSymbol, symbol *obj.Sym) {
 					linker.ExtraData += constants.PtrSize
 				default:
 				}
-			case reloctype.R_GOTPCREL, reloctype.R_ARM64_GOTPCREL:
-				linker.ExtraData += constants.PtrSize
 			}
 
 			if epilogue.Size > 0 {
@@ -70,17 +88,26 @@ func expandFunc(linker *Linker, objsym *obj.ObjSymbol, symbol *obj.Sym) {
 	}
 }
 
-func (linker *Linker) relocateADRP(mCode []byte, loc obj.Reloc, segment *segment, symAddr uintptr) (err error) {
+func (linker *Linker) relocateADRP(symName string, mCode []byte, loc obj.Reloc, segment *segment, symAddr uintptr) (err error) {
 	byteOrder := linker.Arch.ByteOrder
 	offset := int64(symAddr) - ((int64(segment.codeBase) + int64(loc.Offset)) &^ 0xFFF)
 	if loc.Type == reloctype.R_ARM64_GOTPCREL {
-		offset = int64(alignof((segment.dataBase+segment.dataOff)-((segment.codeBase+loc.Offset)&^0xFFF), constants.PtrSize))
-		putAddressAddOffset(byteOrder, segment.dataByte, &segment.dataOff, uint64(symAddr))
+		if slot, ok := linker.gotSlot(segment, loc, symAddr); ok {
+			offset = int64(slot) - int64((segment.codeBase+loc.Offset)&^0xFFF)
+		} else {
+			offset = int64(alignof((segment.dataBase+segment.dataOff)-((segment.codeBase+loc.Offset)&^0xFFF), constants.PtrSize))
+			linker.notifyGOTSlot(loc.SymName, symAddr, uintptr(segment.dataBase+segment.dataOff))
+			putAddressAddOffset(byteOrder, segment.dataByte, &segment.dataOff, uint64(symAddr))
+		}
 	}
 	//overflow
 	if offset >= 1<<32 || offset < -1<<32 {
+		if loc.Epilogue.Size == 0 && !(symAddr < 0xFFFFFFFF && loc.Type == reloctype.R_ADDRARM64) {
+			return fmt.Errorf("relocate %s: %s to %s overflows, but it has no epilogue", symName, reloctype.RelocTypeString(loc.Type), loc.SymName)
+		}
 		epilogueOffset := loc.Epilogue.Offset
 		if symAddr < 0xFFFFFFFF && loc.Type == reloctype.R_ADDRARM64 {
+			linker.notifyFarAddress(symName, loc, symAddr, -1, 0)
 			addr := byteOrder.Uint32(mCode)
 			//low:	MOV reg imm
 			low := uint32(0xD2800000)
@@ -90,6 +117,7 @@ func (linker *Linker) relocateADRP(mCode []byte, loc obj.Reloc, segment *segment
 			high = ((addr & 0x1F) | high) | (uint32(symAddr) >> 16 << 5)
 			byteOrder.PutUint64(mCode, uint64(low)|(uint64(high)<<32))
 		} else {
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, 0)
 			addr := byteOrder.Uint32(mCode)
 			if loc.Type != reloctype.R_ADDRARM64 {
 				addr = uint32(byteOrder.Uint64(mCode) >> 32)
@@ -157,13 +185,21 @@ func (linker *Linker) relocateADRP(mCode []byte, loc obj.Reloc, segment *segment
 	return err
 }
 
-func (linker *Linker) relocateCALL(symAddr uintptr, loc obj.Reloc, segment *segment, relocByte []byte, addrBase int) error {
+func (linker *Linker) relocateCALL(symName string, symAddr uintptr, loc obj.Reloc, segment *segment, relocByte []byte, addrBase int) error {
 	byteOrder := linker.Arch.ByteOrder
 	offset := int(symAddr) - (addrBase + loc.Offset + loc.Size)
-	if isOverflowInt32(offset) {
+	if isOverflowInt32(offset) && loc.Epilogue.Size == 0 {
+		veneerOffset, slot, err := linker.veneer(segment, loc, symAddr)
+		if err != nil {
+			return fmt.Errorf("relocate %s: call to %s overflows, %v", symName, loc.SymName, err)
+		}
+		linker.notifyFarAddress(symName, loc, symAddr, veneerOffset, slot)
+		byteOrder.PutUint32(relocByte[loc.Offset:], uint32(segment.codeBase+veneerOffset-(addrBase+loc.Offset+loc.Size)))
+	} else if isOverflowInt32(offset) {
 		epilogueOffset := loc.Epilogue.Offset
 		switch obj.GetOpName(loc.Op) {
 		case "CALL":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, uintptr(segment.dataBase+segment.dataOff))
 			copy(segment.codeByte[epilogueOffset:], x86amd64ReplaceCALLCode)
 			off := segment.dataBase + segment.dataOff - (segment.codeBase + epilogueOffset + len(x86amd64CALLCode))
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset+2:], uint32(off))
@@ -173,6 +209,7 @@ func (linker *Linker) relocateCALL(symAddr uintptr, loc obj.Reloc, segment *segm
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset-4:], uint32(off))
 			fillCode(relocByte, loc, x86amd64JMPNCode, byteOrder, loc.Epilogue.Offset-loc.GetEnd())
 		case "JMP":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, uintptr(segment.dataBase+segment.dataOff))
 			copy(segment.codeByte[epilogueOffset:], x86amd64JMPLCode)
 			epilogueOffset += len(x86amd64JMPLCode)
 			off := segment.dataBase + segment.dataOff - (segment.codeBase + epilogueOffset)
@@ -203,19 +240,21 @@ func fillCode(relocByte []byte, reloc obj.Reloc, codes []byte, byteorder binary.
 	byteorder.PutUint32(relocByte[reloc.GetEnd()-constants.Uint32Size:], uint32(offset))
 }
 
-func (linker *Linker) relocatePCREL(symAddr uintptr, loc obj.Reloc, segment *segment, relocByte []byte, addrBase int) (err error) {
+func (linker *Linker) relocatePCREL(symName string, symAddr uintptr, loc obj.Reloc, segment *segment, relocByte []byte, addrBase int) (err error) {
 	byteOrder := linker.Arch.ByteOrder
 	offset := int(symAddr) - (addrBase + loc.Offset + loc.Size)
 	if isOverflowInt32(offset) {
 		epilogueOffset := loc.Epilogue.Offset
 		switch obj.GetOpName(loc.Op) {
 		case "LEA":
+			linker.notifyFarAddress(symName, loc, symAddr, -1, uintptr(segment.dataBase+segment.dataOff))
 			relocByte[loc.Offset-2] = x86amd64MOVCode
 			//not append epilogue for LEA, put the address into data segment.
 			offset = (segment.dataBase + segment.dataOff) - (addrBase + loc.GetEnd())
 			byteOrder.PutUint32(relocByte[loc.Offset:], uint32(offset))
 			putAddressAddOffset(byteOrder, segment.dataByte, &segment.dataOff, uint64(symAddr))
 		case "MOV", "MOVUPS", "MOVZ", "MOVZX", "MOVQ", "MOVSD_XMM", "MOVDQU":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, 0)
 			register := (relocByte[loc.Offset-1] >> 3) & 0x7
 			copy(segment.codeByte[epilogueOffset:], x86amd64ReplaceMOVCode)
 			if obj.IsExtraRegister(loc.Args[0]) {
@@ -229,6 +268,7 @@ func (linker *Linker) relocatePCREL(symAddr uintptr, loc obj.Reloc, segment *seg
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset-4:], uint32(loc.GetEnd()-epilogueOffset))
 			fillCode(relocByte, loc, x86amd64JMPNCode, byteOrder, loc.Epilogue.Offset-loc.GetEnd())
 		case "CMP", "CMPL":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, 0)
 			copy(segment.codeByte[epilogueOffset:], x86amd64ReplaceCMPCode)
 			byteOrder.PutUint64(segment.codeByte[epilogueOffset+3:], uint64(symAddr))
 			segment.codeByte[epilogueOffset+14] = relocByte[loc.Offset+loc.Size]
@@ -236,6 +276,7 @@ func (linker *Linker) relocatePCREL(symAddr uintptr, loc obj.Reloc, segment *seg
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset-4:], uint32(loc.GetEnd()-epilogueOffset))
 			fillCode(relocByte, loc, x86amd64JMPNCode, byteOrder, loc.Epilogue.Offset-loc.GetEnd())
 		case "JMP":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, uintptr(segment.dataBase+segment.dataOff))
 			byteOrder.PutUint32(relocByte[loc.Offset:], uint32(epilogueOffset-loc.GetEnd()))
 			copy(segment.codeByte[epilogueOffset:], x86amd64JMPLCode)
 			epilogueOffset += len(x86amd64JMPLCode)
@@ -243,6 +284,7 @@ func (linker *Linker) relocatePCREL(symAddr uintptr, loc obj.Reloc, segment *seg
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset-4:], uint32(off))
 			putAddressAddOffset(byteOrder, segment.dataByte, &segment.dataOff, uint64(symAddr))
 		case "CALL":
+			linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, uintptr(segment.dataBase+segment.dataOff))
 			copy(segment.codeByte[epilogueOffset:], x86amd64ReplaceCALLCode)
 			off := segment.dataBase + segment.dataOff - (segment.codeBase + epilogueOffset + len(x86amd64CALLCode))
 			byteOrder.PutUint32(segment.codeByte[epilogueOffset+2:], uint32(off))
@@ -259,15 +301,25 @@ func (linker *Linker) relocatePCREL(symAddr uintptr, loc obj.Reloc, segment *seg
 	return nil
 }
 
-func (linker *Linker) relocateCALLARM(addr uintptr, loc obj.Reloc, segment *segment) {
+func (linker *Linker) relocateCALLARM(symName string, addr uintptr, loc obj.Reloc, segment *segment) error {
 	byteOrder := linker.Arch.ByteOrder
 	add := loc.Add
 	if loc.Type == reloctype.R_CALLARM {
 		add = int(signext24(int64(loc.Add&0xFFFFFF)) * 4)
 	}
 	offset := (int(addr) + add - (segment.codeBase + loc.Offset)) / 4
-	if isOverflowInt24(offset) {
+	if isOverflowInt24(offset) && loc.Type != reloctype.R_CALLARM && loc.Epilogue.Size == 0 {
+		veneerOffset, _, err := linker.veneer(segment, loc, uintptr(int(addr)+add))
+		if err != nil {
+			return fmt.Errorf("relocate %s: call to %s overflows, %v", symName, loc.SymName, err)
+		}
+		linker.notifyFarAddress(symName, loc, uintptr(int(addr)+add), veneerOffset, 0)
+		offset = (veneerOffset - loc.Offset) / 4
+		val := byteOrder.Uint32(segment.codeByte[loc.Offset:])
+		byteOrder.PutUint32(segment.codeByte[loc.Offset:], val|uint32(offset)&0x03FFFFFF)
+	} else if isOverflowInt24(offset) {
 		epilogueOffset := loc.Epilogue.Offset
+		linker.notifyFarAddress(symName, loc, uintptr(int(addr)+add), epilogueOffset, 0)
 		off := uint32(epilogueOffset-loc.Offset) / 4
 		if loc.Type == reloctype.R_CALLARM {
 			add = int(signext24(int64(loc.Add&0xFFFFFF)+2) * 4)
@@ -291,21 +343,21 @@ func (linker *Linker) relocateCALLARM(addr uintptr, loc obj.Reloc, segment *segm
 		}
 		byteOrder.PutUint32(segment.codeByte[loc.Offset:], val)
 	}
+	return nil
 }
 
 func (linker *Linker) relocate(codeModule *CodeModule, symbolMap, symPtr map[string]uintptr) (err error) {
 	segment := &codeModule.segment
 	byteOrder := linker.Arch.ByteOrder
 	tlsOffset := uint32(tls.GetTLSOffset(linker.Arch, linker.Arch.PtrSize))
-	for _, symbol := range linker.SymMap {
+	for _, name := range sortedKeys(linker.SymMap) {
+		symbol := linker.SymMap[name]
 		interfaceTypeMap := getUseInterfaceTypeMap(symbol)
+		if symkind.IsText(symbol.Kind) {
+			codeModule.relocs[name] = symbol.Reloc
+		}
 		for _, loc := range symbol.Reloc {
-			symAddr := symbolMap[loc.SymName]
-			if isItabName(loc.SymName) && isUseInterfaceMethod(interfaceTypeMap, &loc) {
-				if sym, ok := linker.SymMap[loc.SymName]; ok {
-					symAddr = uintptr(sym.Offset + segment.dataBase)
-				}
-			}
+			symAddr := linker.relocSymbolAddr(loc, interfaceTypeMap, symbolMap, segment)
 			relocByte := segment.dataByte
 			addrBase := segment.dataBase
 			if symkind.IsText(symbol.Kind) {
@@ -319,18 +371,39 @@ func (linker *Linker) relocate(codeModule *CodeModule, symbolMap, symPtr map[str
 				case reloctype.R_TLS_LE, reloctype.R_TLS_IE:
 					byteOrder.PutUint32(relocByte[loc.Offset:], tlsOffset)
 				case reloctype.R_CALL, reloctype.R_CALL | reloctype.R_WEAK:
-					err = linker.relocateCALL(symAddr, loc, segment, relocByte, addrBase)
+					err = linker.relocateCALL(name, symAddr, loc, segment, relocByte, addrBase)
 				case reloctype.R_PCREL:
-					err = linker.relocatePCREL(symAddr, loc, segment, relocByte, addrBase)
+					err = linker.relocatePCREL(name, symAddr, loc, segment, relocByte, addrBase)
 				case reloctype.R_CALLARM, reloctype.R_CALLARM64, reloctype.R_CALLARM64 | reloctype.R_WEAK:
-					linker.relocateCALLARM(symbolMap[loc.SymName], loc, segment)
+					err = linker.relocateCALLARM(name, symbolMap[loc.SymName], loc, segment)
 				case reloctype.R_ADDRARM64, reloctype.R_ARM64_GOTPCREL,
 					reloctype.R_ARM64_PCREL_LDST8, reloctype.R_ARM64_PCREL_LDST16,
 					reloctype.R_ARM64_PCREL_LDST32, reloctype.R_ARM64_PCREL_LDST64:
 					if !symkind.IsText(symbol.Kind) {
 						return fmt.Errorf("impossible!Sym:%s locate not in code segment!\n", loc.SymName)
 					}
-					err = linker.relocateADRP(relocByte[loc.Offset:], loc, segment, symAddr)
+					err = linker.relocateADRP(name, relocByte[loc.Offset:], loc, segment, symAddr)
+				case reloctype.R_RISCV_JAL, reloctype.R_RISCV_CALL, reloctype.R_RISCV_JAL | reloctype.R_WEAK, reloctype.R_RISCV_CALL | reloctype.R_WEAK:
+					loc.Type &^= reloctype.R_WEAK
+					err = linker.relocateCALLRISCV64(name, symAddr, loc, segment)
+				case reloctype.R_RISCV_PCREL_ITYPE, reloctype.R_RISCV_PCREL_STYPE:
+					if !symkind.IsText(symbol.Kind) {
+						return fmt.Errorf("impossible!Sym:%s locate not in code segment!\n", loc.SymName)
+					}
+					err = linker.relocatePCRELRISCV64(name, symAddr, loc, segment)
+				case reloctype.R_RISCV_TLS_IE, reloctype.R_RISCV_TLS_LE:
+					err = linker.relocateTLSRISCV64(loc, segment)
+				case reloctype.R_CALLLOONG64, reloctype.R_CALLLOONG64 | reloctype.R_WEAK:
+					loc.Type &^= reloctype.R_WEAK
+					err = linker.relocateCALLLOONG64(name, symAddr, loc, segment)
+				case reloctype.R_LOONG64_ADDR_HI, reloctype.R_LOONG64_ADDR_LO:
+					if !symkind.IsText(symbol.Kind) {
+						return fmt.Errorf("impossible!Sym:%s locate not in code segment!\n", loc.SymName)
+					}
+					err = linker.relocateADDRLOONG64(name, symAddr, loc, segment)
+				case reloctype.R_LOONG64_TLS_LE_HI, reloctype.R_LOONG64_TLS_LE_LO,
+					reloctype.R_LOONG64_TLS_IE_HI, reloctype.R_LOONG64_TLS_IE_LO:
+					err = linker.relocateTLSLOONG64(loc, segment)
 				case reloctype.R_ADDR, reloctype.R_WEAKADDR:
 					putAddress(byteOrder, relocByte[loc.Offset:], uint64(symAddr))
 				case reloctype.R_CALLIND:
@@ -348,8 +421,13 @@ func (linker *Linker) relocate(codeModule *CodeModule, symbolMap, symPtr map[str
 						return fmt.Errorf("symName:%s relocateType:%s, symbol is not exist!\n", loc.SymName, reloctype.RelocTypeString(loc.Type))
 					}
 				case reloctype.R_GOTPCREL:
+					if slot, ok := linker.gotSlot(segment, loc, symAddr); ok {
+						byteOrder.PutUint32(relocByte[loc.Offset:], uint32(int(slot)-(addrBase+loc.GetEnd())))
+						break
+					}
 					offset := uint32(segment.dataBase + segment.dataOff - (addrBase + loc.GetEnd()))
 					byteOrder.PutUint32(relocByte[loc.Offset:], offset)
+					linker.notifyGOTSlot(loc.SymName, symAddr, uintptr(segment.dataBase+segment.dataOff))
 					putAddressAddOffset(byteOrder, segment.dataByte, &segment.dataOff, uint64(symAddr))
 				case reloctype.R_USETYPE,
 					reloctype.R_USEIFACE,
@@ -372,6 +450,17 @@ func (linker *Linker) relocate(codeModule *CodeModule, symbolMap, symPtr map[str
 	return err
 }
 
+// relocSymbolAddr returns the address which loc is relocated to, before adding loc.Add.
+// an itab used by interface methods is relocated to the itab of module instead of the itab in host
+func (linker *Linker) relocSymbolAddr(loc obj.Reloc, interfaceTypeMap map[string]int, symbolMap map[string]uintptr, segment *segment) uintptr {
+	if isItabName(loc.SymName) && isUseInterfaceMethod(interfaceTypeMap, &loc) {
+		if sym, ok := linker.SymMap[loc.SymName]; ok {
+			return uintptr(sym.Offset + segment.dataBase)
+		}
+	}
+	return symbolMap[loc.SymName]
+}
+
 func getUseInterfaceTypeMap(symbol *obj.Sym) map[string]int {
 	typMap := make(map[string]int)
 	for _, l := range symbol.Reloc {
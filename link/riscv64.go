@@ -0,0 +1,202 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+// sys.ArchRISCV64 is not defined by old toolchains, riscv64 is matched by name
+const archNameRISCV64 = "riscv64"
+
+const (
+	riscv64OpcodeMask   = 0x7F
+	riscv64OpcodeAUIPC  = 0x17
+	riscv64OpcodeLUI    = 0x37
+	riscv64OpcodeADDI   = 0x13
+	riscv64OpcodeJAL    = 0x6F
+	riscv64OpcodeLD     = 0x3003 // opcode and funct3 of LD
+	riscv64RegisterMask = 0x1F
+
+	riscv64UTypeImmMask = 0xFFFFF000
+	riscv64ITypeImmMask = 0xFFF00000
+	riscv64STypeImmMask = 0xFE000F80
+	riscv64JTypeImmMask = 0xFFFFF000
+)
+
+//go:inline
+func isRISCV64(archName string) bool {
+	return archName == archNameRISCV64
+}
+
+// splitRISCV64Offset splits a 32 bit offset into the immediates of an AUIPC or LUI and the following I-type or S-type instruction
+func splitRISCV64Offset(offset int64) (low, high int64, ok bool) {
+	high = (offset + 0x800) >> 12
+	low = offset - high<<12
+	return low, high, high >= -1<<19 && high < 1<<19
+}
+
+func riscv64UTypeImm(imm int64) uint32 {
+	return uint32(imm) << 12
+}
+
+func riscv64ITypeImm(imm int64) uint32 {
+	return uint32(imm) << 20
+}
+
+func riscv64STypeImm(imm int64) uint32 {
+	return (uint32(imm)>>5)<<25 | (uint32(imm)&0x1F)<<7
+}
+
+// riscv64JTypeImm encodes offset into the immediate of a JAL, it reaches ±1MB
+func riscv64JTypeImm(offset int64) (uint32, bool) {
+	if offset < -1<<20 || offset >= 1<<20 || offset&1 != 0 {
+		return 0, false
+	}
+	imm := uint32(offset)
+	return (imm>>20&0x1)<<31 | (imm>>1&0x3FF)<<21 | (imm>>11&0x1)<<20 | (imm>>12&0xFF)<<12, true
+}
+
+// riscv64JAL creates a JAL X0 to offset
+func riscv64JAL(offset int) (uint32, error) {
+	imm, ok := riscv64JTypeImm(int64(offset))
+	if !ok {
+		return 0, fmt.Errorf("jump offset %#x is out of range of JAL", offset)
+	}
+	return imm | riscv64OpcodeJAL, nil
+}
+
+// riscv64PCRELPair patches the immediates of an AUIPC and its following I-type or S-type instruction
+func (linker *Linker) riscv64PCRELPair(code []byte, relocType int, offset int64) bool {
+	byteOrder := linker.Arch.ByteOrder
+	low, high, ok := splitRISCV64Offset(offset)
+	if !ok {
+		return false
+	}
+	auipc := byteOrder.Uint32(code)&^riscv64UTypeImmMask | riscv64UTypeImm(high)
+	second := byteOrder.Uint32(code[4:])
+	if relocType == reloctype.R_RISCV_PCREL_STYPE {
+		second = second&^riscv64STypeImmMask | riscv64STypeImm(low)
+	} else {
+		second = second&^riscv64ITypeImmMask | riscv64ITypeImm(low)
+	}
+	byteOrder.PutUint32(code, auipc)
+	byteOrder.PutUint32(code[4:], second)
+	return true
+}
+
+// relocateCALLRISCV64 relocates a JAL or an AUIPC + JALR pair, a call which overflows jumps to the veneer of its target
+func (linker *Linker) relocateCALLRISCV64(symName string, symAddr uintptr, loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	offset := int64(symAddr) - int64(segment.codeBase+loc.Offset)
+	if loc.Type == reloctype.R_RISCV_JAL {
+		if imm, ok := riscv64JTypeImm(offset); ok {
+			byteOrder.PutUint32(code, byteOrder.Uint32(code)&^riscv64JTypeImmMask|imm)
+			return nil
+		}
+	} else if linker.riscv64PCRELPair(code, loc.Type, offset) {
+		return nil
+	}
+	veneerOffset, _, err := linker.veneer(segment, loc, symAddr)
+	if err != nil {
+		return fmt.Errorf("relocate %s: call to %s overflows, %v", symName, loc.SymName, err)
+	}
+	linker.notifyFarAddress(symName, loc, symAddr, veneerOffset, 0)
+	offset = int64(veneerOffset - loc.Offset)
+	if loc.Type == reloctype.R_RISCV_JAL {
+		imm, ok := riscv64JTypeImm(offset)
+		if !ok {
+			return fmt.Errorf("relocate %s: call to %s overflows, its veneer at %#x is out of range of JAL", symName, loc.SymName, veneerOffset)
+		}
+		byteOrder.PutUint32(code, byteOrder.Uint32(code)&^riscv64JTypeImmMask|imm)
+	} else {
+		linker.riscv64PCRELPair(code, loc.Type, offset)
+	}
+	return nil
+}
+
+// relocatePCRELRISCV64 relocates an AUIPC + I-type or S-type pair,
+// an overflowed pair jumps to its epilogue, which loads the address into the register of AUIPC
+// and executes the I-type or S-type instruction with zero offset:
+//
+//	AUIPC rd, 0
+//	LD rd, address-epilogue(rd)
+//	I-type or S-type instruction
+//	JAL X0, back
+//	address (aligned to 8)
+func (linker *Linker) relocatePCRELRISCV64(symName string, symAddr uintptr, loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	if linker.riscv64PCRELPair(code, loc.Type, int64(symAddr)-int64(segment.codeBase+loc.Offset)) {
+		return nil
+	}
+	if loc.Epilogue.Size == 0 {
+		return fmt.Errorf("relocate %s: %s to %s overflows, but it has no epilogue", symName, reloctype.RelocTypeString(loc.Type), loc.SymName)
+	}
+	epilogueOffset := loc.Epilogue.Offset
+	linker.notifyFarAddress(symName, loc, symAddr, epilogueOffset, 0)
+	auipc := byteOrder.Uint32(code)
+	second := byteOrder.Uint32(code[4:])
+	if loc.Type == reloctype.R_RISCV_PCREL_STYPE {
+		second &^= riscv64STypeImmMask
+	} else {
+		second &^= riscv64ITypeImmMask
+	}
+	jump, err := riscv64JAL(epilogueOffset - loc.Offset)
+	if err != nil {
+		return fmt.Errorf("relocate %s: %s to %s overflows, %v", symName, reloctype.RelocTypeString(loc.Type), loc.SymName, err)
+	}
+	back, err := riscv64JAL(loc.Offset + loc.Size - (epilogueOffset + 12))
+	if err != nil {
+		return fmt.Errorf("relocate %s: %s to %s overflows, %v", symName, reloctype.RelocTypeString(loc.Type), loc.SymName, err)
+	}
+	rd := auipc >> 7 & riscv64RegisterMask
+	addressOffset := alignof(epilogueOffset+16, 8)
+	epilogue := segment.codeByte[epilogueOffset:]
+	byteOrder.PutUint32(epilogue, riscv64OpcodeAUIPC|rd<<7)
+	byteOrder.PutUint32(epilogue[4:], riscv64OpcodeLD|rd<<7|rd<<15|riscv64ITypeImm(int64(addressOffset-epilogueOffset)))
+	byteOrder.PutUint32(epilogue[8:], second)
+	byteOrder.PutUint32(epilogue[12:], back)
+	putAddress(byteOrder, segment.codeByte[addressOffset:], uint64(symAddr))
+	byteOrder.PutUint32(code, jump)
+	return nil
+}
+
+// relocateTLSRISCV64 relocates the offset of a TLS symbol from TP, an initial-exec AUIPC + LD pair is relaxed to
+// a local-exec LUI + ADDI pair. runtime.tls_g is the only TLS symbol of go, the go linker places it at
+// the beginning of TLS block and relocates it with its addend only
+func (linker *Linker) relocateTLSRISCV64(loc obj.Reloc, segment *segment) error {
+	byteOrder := linker.Arch.ByteOrder
+	code := segment.codeByte[loc.Offset:]
+	low, high, ok := splitRISCV64Offset(int64(loc.Add))
+	if !ok {
+		return fmt.Errorf("TLS offset %#x of %s does not fit in 32 bits", loc.Add, loc.SymName)
+	}
+	first := byteOrder.Uint32(code)
+	second := byteOrder.Uint32(code[4:])
+	if loc.Type == reloctype.R_RISCV_TLS_IE {
+		first = first&^riscv64OpcodeMask | riscv64OpcodeLUI
+		// keep rd and rs1 of LD, replace funct3 and opcode with those of ADDI
+		second = second&(riscv64RegisterMask<<7|riscv64RegisterMask<<15) | riscv64OpcodeADDI
+	}
+	byteOrder.PutUint32(code, first&^riscv64UTypeImmMask|riscv64UTypeImm(high))
+	byteOrder.PutUint32(code[4:], second&^riscv64ITypeImmMask|riscv64ITypeImm(low))
+	return nil
+}
+
+func createRISCV64Nops(size int) []byte {
+	if size%len(riscv64CNOPCode) != 0 {
+		panic(fmt.Sprintf("can't make nop instruction if padding is not multiple of %d, got %d", len(riscv64CNOPCode), size))
+	}
+	nops := make([]byte, size)
+	i := 0
+	for ; i+len(riscv64NOPCode) <= size; i += len(riscv64NOPCode) {
+		copy(nops[i:], riscv64NOPCode)
+	}
+	if i < size {
+		copy(nops[i:], riscv64CNOPCode)
+	}
+	return nops
+}
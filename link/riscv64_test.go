@@ -0,0 +1,264 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package link
+
+import (
+	"testing"
+
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+)
+
+const (
+	riscv64OpcodeJALR  = 0x67
+	riscv64OpcodeADDIW = 0x1B
+	riscv64Funct3Mask  = 0x7000
+	riscv64RegisterX31 = 31
+)
+
+var riscv64TestFiles = map[string]string{
+	"p.go": `package rvtest
+
+var counter int
+var table [4096]int64
+
+func tlsLoad() int64
+func tlsStore(v int64)
+
+//go:noinline
+func Store(v int) {
+	counter = v
+	table[v&4095] = int64(v)
+}
+
+func Load(v int) int64 {
+	Store(v)
+	tlsStore(int64(v))
+	return table[v&4095] + int64(counter) + tlsLoad()
+}
+`,
+	"p_riscv64.s": `#include "textflag.h"
+
+GLOBL runtime·tls_g(SB), TLSBSS, $8
+
+TEXT ·tlsLoad(SB), NOSPLIT|NOFRAME, $0-8
+	MOV	runtime·tls_g(SB), X10
+	MOV	X10, ret+0(FP)
+	RET
+
+TEXT ·tlsStore(SB), NOSPLIT|NOFRAME, $0-8
+	MOV	v+0(FP), X10
+	MOV	X10, runtime·tls_g(SB)
+	RET
+`,
+}
+
+func riscv64Opcode(inst uint32) uint32 {
+	return inst & riscv64OpcodeMask
+}
+
+func riscv64Rd(inst uint32) uint32 {
+	return inst >> 7 & riscv64RegisterMask
+}
+
+func riscv64Rs1(inst uint32) uint32 {
+	return inst >> 15 & riscv64RegisterMask
+}
+
+func riscv64DecodeUImm(inst uint32) int64 {
+	return int64(int32(inst & riscv64UTypeImmMask))
+}
+
+func riscv64DecodeIImm(inst uint32) int64 {
+	return int64(int32(inst) >> 20)
+}
+
+func riscv64DecodeSImm(inst uint32) int64 {
+	return int64(int32(inst&0xFE000000)>>20) | int64(inst>>7&0x1F)
+}
+
+func riscv64DecodeJImm(inst uint32) int64 {
+	return int64(int32(inst&0x80000000)>>11 | int32(inst&0xFF000) | int32(inst>>9&0x800) | int32(inst>>20&0x7FE))
+}
+
+// decodeRISCV64Call follows a call to target, through the veneer of its target if target is a veneer
+func decodeRISCV64Call(t *testing.T, cm *CodeModule, target uintptr) crossTarget {
+	off := int(target) - cm.codeBase - cm.veneerOff
+	if cm.veneerLen == 0 || off < 0 || off >= cm.veneerLen {
+		return crossTarget{addr: target, path: "direct"}
+	}
+	byteOrder := cm.arch.ByteOrder
+	veneer := cm.codeByte[cm.veneerOff+off:]
+	auipc, ld, jalr := byteOrder.Uint32(veneer), byteOrder.Uint32(veneer[4:]), byteOrder.Uint32(veneer[8:])
+	if off%veneerSizeRISCV64 != 0 ||
+		riscv64Opcode(auipc) != riscv64OpcodeAUIPC || riscv64Rd(auipc) != riscv64RegisterX31 || riscv64DecodeUImm(auipc) != 0 ||
+		ld&(riscv64Funct3Mask|riscv64OpcodeMask) != riscv64OpcodeLD || riscv64Rd(ld) != riscv64RegisterX31 || riscv64Rs1(ld) != riscv64RegisterX31 ||
+		riscv64Opcode(jalr) != riscv64OpcodeJALR || riscv64Rd(jalr) != 0 || riscv64Rs1(jalr) != riscv64RegisterX31 || riscv64DecodeIImm(jalr) != 0 {
+		t.Errorf("invalid veneer at %#x: %#x %#x %#x", target, auipc, ld, jalr)
+		return crossTarget{addr: target, path: "invalid veneer"}
+	}
+	return crossTarget{addr: uintptr(getAddress(byteOrder, veneer[riscv64DecodeIImm(ld):])), path: "veneer"}
+}
+
+func decodeRISCV64(t *testing.T, linker *Linker, cm *CodeModule, loc obj.Reloc) crossTarget {
+	byteOrder := cm.arch.ByteOrder
+	code := cm.codeByte[loc.Offset:]
+	pc := int64(cm.codeBase + loc.Offset)
+	first, second := byteOrder.Uint32(code), uint32(0)
+	if loc.Size == 8 {
+		second = byteOrder.Uint32(code[4:])
+	}
+	switch loc.Type {
+	case reloctype.R_RISCV_JAL:
+		if riscv64Opcode(first) != riscv64OpcodeJAL {
+			t.Errorf("%s to %s is not a JAL: %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, first)
+		}
+		return decodeRISCV64Call(t, cm, uintptr(pc+riscv64DecodeJImm(first)))
+	case reloctype.R_RISCV_CALL:
+		if riscv64Opcode(first) != riscv64OpcodeAUIPC || riscv64Opcode(second) != riscv64OpcodeJALR || riscv64Rs1(second) != riscv64Rd(first) {
+			t.Errorf("%s to %s is not an AUIPC + JALR pair: %#x %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, first, second)
+		}
+		return decodeRISCV64Call(t, cm, uintptr(pc+riscv64DecodeUImm(first)+riscv64DecodeIImm(second)))
+	case reloctype.R_RISCV_PCREL_ITYPE, reloctype.R_RISCV_PCREL_STYPE:
+		decodeImm, immMask := riscv64DecodeIImm, uint32(riscv64ITypeImmMask)
+		if loc.Type == reloctype.R_RISCV_PCREL_STYPE {
+			decodeImm, immMask = riscv64DecodeSImm, riscv64STypeImmMask
+		}
+		if riscv64Opcode(first) == riscv64OpcodeAUIPC {
+			return crossTarget{addr: uintptr(pc + riscv64DecodeUImm(first) + decodeImm(second)), path: "direct"}
+		}
+		epilogueOffset := loc.Offset + int(riscv64DecodeJImm(first))
+		if riscv64Opcode(first) != riscv64OpcodeJAL || epilogueOffset != loc.Epilogue.Offset {
+			t.Errorf("%s to %s doesn't jump to its epilogue: %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, first)
+			return crossTarget{path: "invalid epilogue"}
+		}
+		original := linker.Code[loc.Offset:]
+		epilogue := cm.codeByte[epilogueOffset:]
+		auipc, ld, inst, back := byteOrder.Uint32(epilogue), byteOrder.Uint32(epilogue[4:]), byteOrder.Uint32(epilogue[8:]), byteOrder.Uint32(epilogue[12:])
+		rd := riscv64Rd(byteOrder.Uint32(original))
+		addressOffset := epilogueOffset + int(riscv64DecodeIImm(ld))
+		if auipc != riscv64OpcodeAUIPC|rd<<7 || ld&(riscv64Funct3Mask|riscv64OpcodeMask) != riscv64OpcodeLD ||
+			riscv64Rd(ld) != rd || riscv64Rs1(ld) != rd || addressOffset%8 != 0 ||
+			inst != byteOrder.Uint32(original[4:])&^immMask ||
+			riscv64Opcode(back) != riscv64OpcodeJAL || riscv64Rd(back) != 0 || epilogueOffset+12+int(riscv64DecodeJImm(back)) != loc.Offset+loc.Size {
+			t.Errorf("invalid epilogue of %s to %s: %#x %#x %#x %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, auipc, ld, inst, back)
+			return crossTarget{path: "invalid epilogue"}
+		}
+		return crossTarget{addr: uintptr(getAddress(byteOrder, cm.codeByte[addressOffset:])), path: "epilogue"}
+	case reloctype.R_RISCV_TLS_IE, reloctype.R_RISCV_TLS_LE:
+		original := linker.Code[loc.Offset:]
+		originalSecond := byteOrder.Uint32(original[4:])
+		secondOpcode := originalSecond & (riscv64Funct3Mask | riscv64OpcodeMask)
+		if loc.Type == reloctype.R_RISCV_TLS_IE {
+			secondOpcode = riscv64OpcodeADDI
+		}
+		if riscv64Opcode(first) != riscv64OpcodeLUI || riscv64Rd(first) != riscv64Rd(byteOrder.Uint32(original)) ||
+			second&(riscv64Funct3Mask|riscv64OpcodeMask) != secondOpcode ||
+			riscv64Rd(second) != riscv64Rd(originalSecond) || riscv64Rs1(second) != riscv64Rs1(originalSecond) {
+			t.Errorf("%s to %s is not relaxed to LUI + ADDI(W): %#x %#x", reloctype.RelocTypeString(loc.Type), loc.SymName, first, second)
+		}
+		return crossTarget{addr: uintptr(riscv64DecodeUImm(first) + riscv64DecodeIImm(second)), path: "tls"}
+	}
+	return crossTarget{}
+}
+
+func TestRelocateRISCV64Objs(t *testing.T) {
+	testCases := []struct {
+		name  string
+		flags []string
+		far   bool
+		want  map[int][]string
+	}{
+		{
+			name: "near",
+			want: map[int][]string{
+				reloctype.R_RISCV_JAL:         {"direct"},
+				reloctype.R_RISCV_PCREL_ITYPE: {"direct"},
+				reloctype.R_RISCV_PCREL_STYPE: {"direct"},
+				reloctype.R_RISCV_TLS_LE:      {"tls"},
+			},
+		},
+		{
+			name: "far",
+			far:  true,
+			want: map[int][]string{
+				reloctype.R_RISCV_JAL:         {"direct", "veneer"},
+				reloctype.R_RISCV_PCREL_ITYPE: {"epilogue"},
+				reloctype.R_RISCV_PCREL_STYPE: {"epilogue"},
+				reloctype.R_RISCV_TLS_LE:      {"tls"},
+			},
+		},
+		{
+			name:  "initial exec TLS",
+			flags: []string{"-asmflags=-shared"},
+			want: map[int][]string{
+				reloctype.R_RISCV_TLS_IE: {"tls"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			linker := readCrossObjs(t, archNameRISCV64, "rvtest", riscv64TestFiles, testCase.flags...)
+			cm, symbolMap := relocateCrossObjs(t, linker, testCase.far)
+			paths := checkCrossRelocs(t, linker, cm, symbolMap, decodeRISCV64)
+			for relocType, want := range testCase.want {
+				for _, path := range want {
+					if paths[relocType][path] == 0 {
+						t.Errorf("no %s is relocated through %s, got %v", reloctype.RelocTypeString(relocType), path, paths[relocType])
+					}
+				}
+			}
+		})
+	}
+}
+
+// the go compiler only emits R_RISCV_CALL for functions larger than 1MB, so an AUIPC + JALR pair is relocated alone
+func TestRelocateCALLRISCV64(t *testing.T) {
+	if uint64(uintptr(crossFar)) != crossFar {
+		t.Skip("relocations of 64-bit objs are not tested on 32-bit hosts")
+	}
+	testCases := []struct {
+		name   string
+		offset int64
+		path   string
+	}{
+		{name: "near", offset: 0x1000, path: "direct"},
+		{name: "backward", offset: -0x1234, path: "direct"},
+		{name: "last forward", offset: 1<<31 - 0x801, path: "direct"},
+		{name: "first backward", offset: -1<<31 - 0x800, path: "direct"},
+		{name: "overflow forward", offset: 1<<31 - 0x800, path: "veneer"},
+		{name: "overflow backward", offset: -1<<31 - 0x801, path: "veneer"},
+		{name: "far", offset: int64(crossFar), path: "veneer"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			linker := initLinker()
+			linker.Arch = getArch(archNameRISCV64)
+			loc := obj.Reloc{Offset: 0, Size: 8, Type: reloctype.R_RISCV_CALL, SymName: "host.f"}
+			linker.addVeneer(loc)
+			// AUIPC X31, 0; JALR X1, 0(X31)
+			linker.Code = make([]byte, loc.Size)
+			linker.Arch.ByteOrder.PutUint32(linker.Code, riscv64OpcodeAUIPC|riscv64RegisterX31<<7)
+			linker.Arch.ByteOrder.PutUint32(linker.Code[4:], riscv64OpcodeJALR|1<<7|riscv64RegisterX31<<15)
+			cm := &CodeModule{arch: linker.Arch}
+			cm.codeBase = crossCodeBase
+			cm.veneerOff = veneerAlign
+			cm.veneerLen = len(linker.Veneers) * veneerSizeRISCV64
+			cm.codeByte = make([]byte, cm.veneerOff+cm.veneerLen)
+			copy(cm.codeByte, linker.Code)
+
+			target := uintptr(crossCodeBase + testCase.offset)
+			if err := linker.relocateCALLRISCV64("test", target, loc, &cm.segment); err != nil {
+				t.Fatal(err)
+			}
+			decoded := decodeRISCV64(t, linker, cm, loc)
+			if decoded.addr != target || decoded.path != testCase.path {
+				t.Errorf("call is relocated to %#x through %s, want %#x through %s", decoded.addr, decoded.path, target, testCase.path)
+			}
+			if first := linker.Arch.ByteOrder.Uint32(cm.codeByte); riscv64Rd(first) != riscv64RegisterX31 {
+				t.Errorf("rd of AUIPC is changed: %#x", first)
+			}
+		})
+	}
+}
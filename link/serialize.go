@@ -1,30 +1,393 @@
 package link
 
 import (
+	"bytes"
+	"cmd/objfile/sys"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"runtime"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+)
+
+const (
+	SerializeMagic   = "goloader" // first bytes of a serialized Linker
+	SignedMagic      = "glsigned" // first bytes of a signed serialized Linker
+	serializeVersion = 4
+	goloaderPath     = "github.com/pkujhd/goloader"
+	develVersion     = "(devel)"
+	dirtySuffix      = "+dirty"
+	maxHeaderLen     = 1 << 16
 )
 
+// SerializeHeader is written before the gob encoded Linker, it records the environment which produced the Linker
+type SerializeHeader struct {
+	FormatVersion   int
+	GoVersion       string
+	GOOS            string
+	GOARCH          string
+	GoloaderVersion string
+	LayoutHash      string
+}
+
+// gob encodes maps in random order, serializedLinker stores every map of Linker as a slice sorted by key,
+// so the same Linker always be serialized to identical bytes
+type serializedLinker struct {
+	LinkerData
+	Syms                []symEntry
+	ObjSymbols          []objSymbolEntry
+	Names               []nameEntry
+	Strings             []stringEntry
+	CgoImports          []cgoImportEntry
+	CgoFuncs            []nameEntry
+	UnImplementedTypes  []unImplementedTypeEntry
+	Filetab             []uint32
+	Funcs               []*_func
+	Packages            []pkgEntry
+	Arch                *sys.Arch
+	ExtraData           int
+	CUOffset            int32
+	AdaptedOffset       bool
+	PackedLayout        bool
+	FarAddressEpilogues bool
+	Veneers             []nameEntry
+	GOTSlots            []nameEntry
+	NearHost            bool
+	FuncTypes           []stringEntry
+}
+
+type symEntry struct {
+	Name string
+	Sym  *obj.Sym
+}
+
+type objSymbolEntry struct {
+	Name      string
+	ObjSymbol *obj.ObjSymbol
+}
+
+type nameEntry struct {
+	Name  string
+	Value int
+}
+
+type stringEntry struct {
+	Name  string
+	Value *string
+}
+
+type cgoImportEntry struct {
+	Name      string
+	CgoImport *obj.CgoImport
+}
+
+type unImplementedTypeEntry struct {
+	Name  string
+	Types []nameEntry
+}
+
+type pkgEntry struct {
+	Pkg        *obj.Pkg
+	Syms       []objSymbolEntry
+	CgoImports []cgoImportEntry
+}
+
+func toNameEntries(m map[string]int) []nameEntry {
+	entries := make([]nameEntry, 0, len(m))
+	for _, name := range sortedKeys(m) {
+		entries = append(entries, nameEntry{Name: name, Value: m[name]})
+	}
+	return entries
+}
+
+func toObjSymbolEntries(m map[string]*obj.ObjSymbol) []objSymbolEntry {
+	entries := make([]objSymbolEntry, 0, len(m))
+	for _, name := range sortedKeys(m) {
+		entries = append(entries, objSymbolEntry{Name: name, ObjSymbol: m[name]})
+	}
+	return entries
+}
+
+func toCgoImportEntries(m map[string]*obj.CgoImport) []cgoImportEntry {
+	entries := make([]cgoImportEntry, 0, len(m))
+	for _, name := range sortedKeys(m) {
+		entries = append(entries, cgoImportEntry{Name: name, CgoImport: m[name]})
+	}
+	return entries
+}
+
+func fromObjSymbolEntries(entries []objSymbolEntry) map[string]*obj.ObjSymbol {
+	m := make(map[string]*obj.ObjSymbol, len(entries))
+	for _, entry := range entries {
+		m[entry.Name] = entry.ObjSymbol
+	}
+	return m
+}
+
+func fromCgoImportEntries(entries []cgoImportEntry) map[string]*obj.CgoImport {
+	m := make(map[string]*obj.CgoImport, len(entries))
+	for _, entry := range entries {
+		m[entry.Name] = entry.CgoImport
+	}
+	return m
+}
+
+func fromNameEntries(entries []nameEntry) map[string]int {
+	m := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		m[entry.Name] = entry.Value
+	}
+	return m
+}
+
+func (linker *Linker) toSerialized() *serializedLinker {
+	s := &serializedLinker{
+		LinkerData:          linker.LinkerData,
+		ObjSymbols:          toObjSymbolEntries(linker.ObjSymbolMap),
+		Names:               toNameEntries(linker.NameMap),
+		CgoImports:          toCgoImportEntries(linker.CgoImportMap),
+		CgoFuncs:            toNameEntries(linker.CgoFuncs),
+		Filetab:             linker.Filetab,
+		Funcs:               linker.Funcs,
+		Arch:                linker.Arch,
+		ExtraData:           linker.ExtraData,
+		CUOffset:            linker.CUOffset,
+		AdaptedOffset:       linker.AdaptedOffset,
+		PackedLayout:        linker.PackedLayout,
+		FarAddressEpilogues: linker.FarAddressEpilogues,
+		Veneers:             toNameEntries(linker.Veneers),
+		GOTSlots:            toNameEntries(linker.GOTSlots),
+		NearHost:            linker.NearHost,
+	}
+	for _, name := range sortedKeys(linker.SymMap) {
+		s.Syms = append(s.Syms, symEntry{Name: name, Sym: linker.SymMap[name]})
+	}
+	for _, name := range sortedKeys(linker.StringMap) {
+		s.Strings = append(s.Strings, stringEntry{Name: name, Value: linker.StringMap[name]})
+	}
+	for _, name := range sortedKeys(linker.FuncTypes) {
+		typeName := linker.FuncTypes[name]
+		s.FuncTypes = append(s.FuncTypes, stringEntry{Name: name, Value: &typeName})
+	}
+	for _, name := range sortedKeys(linker.UnImplementedTypes) {
+		s.UnImplementedTypes = append(s.UnImplementedTypes, unImplementedTypeEntry{Name: name, Types: toNameEntries(linker.UnImplementedTypes[name])})
+	}
+	for _, pkgPath := range sortedKeys(linker.Packages) {
+		pkg := *linker.Packages[pkgPath]
+		entry := pkgEntry{Pkg: &pkg, Syms: toObjSymbolEntries(pkg.Syms), CgoImports: toCgoImportEntries(pkg.CgoImports)}
+		pkg.Syms, pkg.CgoImports = nil, nil
+		s.Packages = append(s.Packages, entry)
+	}
+	return s
+}
+
+func (s *serializedLinker) toLinker() *Linker {
+	linker := initLinker()
+	linker.LinkerData = s.LinkerData
+	for _, entry := range s.Syms {
+		linker.SymMap[entry.Name] = entry.Sym
+	}
+	if len(s.ObjSymbols) > 0 {
+		linker.ObjSymbolMap = fromObjSymbolEntries(s.ObjSymbols)
+	}
+	linker.NameMap = fromNameEntries(s.Names)
+	for _, entry := range s.Strings {
+		linker.StringMap[entry.Name] = entry.Value
+	}
+	linker.CgoImportMap = fromCgoImportEntries(s.CgoImports)
+	linker.CgoFuncs = fromNameEntries(s.CgoFuncs)
+	for _, entry := range s.UnImplementedTypes {
+		linker.UnImplementedTypes[entry.Name] = fromNameEntries(entry.Types)
+	}
+	linker.Filetab = s.Filetab
+	linker.Funcs = s.Funcs
+	for _, entry := range s.Packages {
+		if len(entry.Syms) > 0 {
+			entry.Pkg.Syms = fromObjSymbolEntries(entry.Syms)
+		}
+		entry.Pkg.CgoImports = fromCgoImportEntries(entry.CgoImports)
+		linker.Packages[entry.Pkg.PkgPath] = entry.Pkg
+	}
+	linker.Arch = s.Arch
+	linker.ExtraData = s.ExtraData
+	linker.CUOffset = s.CUOffset
+	linker.AdaptedOffset = s.AdaptedOffset
+	linker.PackedLayout = s.PackedLayout
+	linker.FarAddressEpilogues = s.FarAddressEpilogues
+	linker.Veneers = fromNameEntries(s.Veneers)
+	linker.GOTSlots = fromNameEntries(s.GOTSlots)
+	linker.NearHost = s.NearHost
+	for _, entry := range s.FuncTypes {
+		linker.FuncTypes[entry.Name] = *entry.Value
+	}
+	return linker
+}
+
+// layoutHash hashes the layout of runtime structures which are written by relocation and buildModule
+func layoutHash() string {
+	hash := sha256.New()
+	var hashType func(typ reflect.Type)
+	hashType = func(typ reflect.Type) {
+		fmt.Fprintf(hash, "%s:%d:%d;", typ.Kind(), typ.Size(), typ.Align())
+		if typ.Kind() == reflect.Struct {
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				fmt.Fprintf(hash, "%s@%d:", field.Name, field.Offset)
+				hashType(field.Type)
+			}
+		}
+	}
+	for _, v := range []interface{}{moduledata{}, _func{}, functab{}, findfuncbucket{}, textsect{}, _type{}, itab{}, uncommonType{}} {
+		hashType(reflect.TypeOf(v))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func newSerializeHeader() *SerializeHeader {
+	return &SerializeHeader{
+		FormatVersion:   serializeVersion,
+		GoVersion:       runtime.Version(),
+		GOOS:            runtime.GOOS,
+		GOARCH:          runtime.GOARCH,
+		GoloaderVersion: goloaderVersion(),
+		LayoutHash:      layoutHash(),
+	}
+}
+
+// CheckCompatible returns an error if a Linker serialized with header can not be loaded by current process
+func (h *SerializeHeader) CheckCompatible() error {
+	current := newSerializeHeader()
+	if h.FormatVersion != current.FormatVersion {
+		return fmt.Errorf("incompatible serialized linker: format version %d, expected %d", h.FormatVersion, current.FormatVersion)
+	}
+	items := []struct{ name, got, want string }{
+		{"go version", h.GoVersion, current.GoVersion},
+		{"GOOS", h.GOOS, current.GOOS},
+		{"GOARCH", h.GOARCH, current.GOARCH},
+		{"runtime layout hash", h.LayoutHash, current.LayoutHash},
+	}
+	// a development build of goloader can not be identified by version, the runtime layout hash is checked only
+	if h.GoloaderVersion != develVersion && current.GoloaderVersion != develVersion {
+		items = append(items, struct{ name, got, want string }{"goloader version", h.GoloaderVersion, current.GoloaderVersion})
+	}
+	for _, item := range items {
+		if item.got != item.want {
+			return fmt.Errorf("incompatible serialized linker: %s %s, expected %s", item.name, item.got, item.want)
+		}
+	}
+	return nil
+}
+
+// ReadSerializeHeader reads the header of a serialized Linker without checking compatibility
+func ReadSerializeHeader(reader io.Reader) (*SerializeHeader, error) {
+	magic := make([]byte, len(SerializeMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return nil, fmt.Errorf("read serialized linker header error: %v", err)
+	}
+	if string(magic) == SignedMagic {
+		return nil, errors.New("serialized linker is signed, verify it with UnSerializeVerified or SetTrustedKeys")
+	}
+	if string(magic) != SerializeMagic {
+		return nil, errors.New("not a serialized linker or serialized without header, serialize it again")
+	}
+	var headerLen uint32
+	if err := binary.Read(reader, binary.LittleEndian, &headerLen); err != nil {
+		return nil, fmt.Errorf("read serialized linker header error: %v", err)
+	}
+	if headerLen > maxHeaderLen {
+		return nil, fmt.Errorf("serialized linker header length %d too large", headerLen)
+	}
+	headerBytes := make([]byte, headerLen)
+	if _, err := io.ReadFull(reader, headerBytes); err != nil {
+		return nil, fmt.Errorf("read serialized linker header error: %v", err)
+	}
+	header := &SerializeHeader{}
+	if err := gob.NewDecoder(bytes.NewReader(headerBytes)).Decode(header); err != nil {
+		return nil, fmt.Errorf("read serialized linker header error: %v", err)
+	}
+	return header, nil
+}
+
 func Serialize(linker *Linker, writer io.Writer) error {
 	gob.Register(binary.LittleEndian)
 	gob.Register(binary.BigEndian)
+	// the header is length prefixed, gob decoder may read ahead from a reader which is not an io.ByteReader
+	// objs may be compiled for another platform than the one which serializes them
+	header := newSerializeHeader()
+	if linker.Arch != nil {
+		header.GOARCH = linker.Arch.Name
+	}
+	if linker.GOOS != constants.EmptyString {
+		header.GOOS = linker.GOOS
+	}
+	headerBytes := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(headerBytes).Encode(header); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, SerializeMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, binary.LittleEndian, uint32(headerBytes.Len())); err != nil {
+		return err
+	}
+	if _, err := writer.Write(headerBytes.Bytes()); err != nil {
+		return err
+	}
 	encoder := gob.NewEncoder(writer)
-	err := encoder.Encode(linker)
+	err := encoder.Encode(linker.toSerialized())
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// UnSerialize reads a serialized Linker, if trusted keys are set by SetTrustedKeys,
+// only a bundle signed by one of them is accepted, as UnSerializeVerified does
 func UnSerialize(reader io.Reader) (*Linker, error) {
+	if linker, verified, err := unSerializeTrusted(reader); verified {
+		return linker, err
+	}
+	return unSerialize(reader)
+}
+
+func unSerialize(reader io.Reader) (*Linker, error) {
 	gob.Register(binary.LittleEndian)
 	gob.Register(binary.BigEndian)
-	linker := initLinker()
-	decoder := gob.NewDecoder(reader)
-	err := decoder.Decode(linker)
+	header, err := ReadSerializeHeader(reader)
 	if err != nil {
 		return nil, err
 	}
-	return linker, nil
+	if err = header.CheckCompatible(); err != nil {
+		return nil, err
+	}
+	return readSerializedLinker(reader)
+}
+
+// ReadSerialized reads a serialized Linker without checking compatibility, for inspecting a Linker serialized
+// for another platform, go or goloader version, which must not be loaded. header is returned if it is read
+func ReadSerialized(reader io.Reader) (*SerializeHeader, *Linker, error) {
+	gob.Register(binary.LittleEndian)
+	gob.Register(binary.BigEndian)
+	header, err := ReadSerializeHeader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	linker, err := readSerializedLinker(reader)
+	return header, linker, err
+}
+
+func readSerializedLinker(reader io.Reader) (*Linker, error) {
+	serialized := &serializedLinker{}
+	decoder := gob.NewDecoder(reader)
+	if err := decoder.Decode(serialized); err != nil {
+		return nil, err
+	}
+	return serialized.toLinker(), nil
 }
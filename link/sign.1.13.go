@@ -0,0 +1,106 @@
+//go:build go1.13
+// +build go1.13
+
+package link
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// signed bundle layout: SignedMagic | uint64 payload length | payload | ed25519 signature of payload,
+// payload is the output of Serialize
+
+var (
+	trustedKeysLock sync.RWMutex
+	trustedKeys     []ed25519.PublicKey
+)
+
+// SetTrustedKeys sets the policy of UnSerialize, if keys is not empty, UnSerialize rejects unsigned bundles and
+// verifies signed bundles with keys, plugin.Open rejects go object files. an empty keys removes the policy
+func SetTrustedKeys(keys []ed25519.PublicKey) {
+	trustedKeysLock.Lock()
+	defer trustedKeysLock.Unlock()
+	trustedKeys = append([]ed25519.PublicKey(nil), keys...)
+}
+
+func getTrustedKeys() []ed25519.PublicKey {
+	trustedKeysLock.RLock()
+	defer trustedKeysLock.RUnlock()
+	return trustedKeys
+}
+
+// SignedRequired reports whether trusted keys are set by SetTrustedKeys, so only signed bundles are loaded
+func SignedRequired() bool {
+	return len(getTrustedKeys()) > 0
+}
+
+// unSerializeTrusted verifies reader with the trusted keys, verified is false if no trusted key is set
+func unSerializeTrusted(reader io.Reader) (linker *Linker, verified bool, err error) {
+	keys := getTrustedKeys()
+	if len(keys) == 0 {
+		return nil, false, nil
+	}
+	linker, err = UnSerializeVerified(reader, keys)
+	return linker, true, err
+}
+
+// SerializeSigned serializes linker and signs the whole serialized payload with privateKey
+func SerializeSigned(linker *Linker, writer io.Writer, privateKey ed25519.PrivateKey) error {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return fmt.Errorf("invalid ed25519 private key size %d", len(privateKey))
+	}
+	payload := bytes.NewBuffer(nil)
+	if err := Serialize(linker, payload); err != nil {
+		return err
+	}
+	signature := ed25519.Sign(privateKey, payload.Bytes())
+	if _, err := io.WriteString(writer, SignedMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, binary.LittleEndian, uint64(payload.Len())); err != nil {
+		return err
+	}
+	if _, err := writer.Write(payload.Bytes()); err != nil {
+		return err
+	}
+	_, err := writer.Write(signature)
+	return err
+}
+
+// UnSerializeVerified verifies the signature of a signed bundle with trustedKeys before decoding it,
+// unsigned bundles and bundles not signed by any of trustedKeys are rejected
+func UnSerializeVerified(reader io.Reader, trustedKeys []ed25519.PublicKey) (*Linker, error) {
+	if len(trustedKeys) == 0 {
+		return nil, errors.New("no trusted key to verify serialized linker")
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(SignedMagic) || string(data[:len(SignedMagic)]) != SignedMagic {
+		return nil, errors.New("serialized linker is not signed, sign it with SerializeSigned")
+	}
+	data = data[len(SignedMagic):]
+	if len(data) < binary.Size(uint64(0)) {
+		return nil, errors.New("signed serialized linker is truncated")
+	}
+	payloadLen := binary.LittleEndian.Uint64(data)
+	data = data[binary.Size(uint64(0)):]
+	if len(data) < ed25519.SignatureSize || payloadLen != uint64(len(data)-ed25519.SignatureSize) {
+		return nil, fmt.Errorf("signed serialized linker size %d mismatch with payload size %d", len(data), payloadLen)
+	}
+	payload, signature := data[:payloadLen], data[payloadLen:]
+	for _, key := range trustedKeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, payload, signature) {
+			return unSerialize(bytes.NewReader(payload))
+		}
+	}
+	return nil, errors.New("signature of serialized linker is not verified by any trusted key")
+}
@@ -0,0 +1,63 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package link
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnSerializeTrustedKeys(t *testing.T) {
+	// the instruction decoder of host is only initialized by RegSymbol, so objs are read for riscv64,
+	// and the header records host arch to be compatible
+	linker := readCrossObjs(t, "riscv64", "verifytest", verifyTestFiles)
+	linker.Arch = getArch(runtime.GOARCH)
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, signed := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	if err = Serialize(linker, unsigned); err != nil {
+		t.Fatal(err)
+	}
+	if err = SerializeSigned(linker, signed, privateKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = UnSerialize(bytes.NewReader(signed.Bytes())); err == nil || !strings.Contains(err.Error(), "signed") {
+		t.Errorf("signed bundle without trusted keys: %v", err)
+	}
+	if _, err = UnSerialize(bytes.NewReader(unsigned.Bytes())); err != nil {
+		t.Errorf("unsigned bundle without trusted keys: %v", err)
+	}
+
+	SetTrustedKeys([]ed25519.PublicKey{publicKey})
+	defer SetTrustedKeys(nil)
+	if !SignedRequired() {
+		t.Error("SignedRequired is false with trusted keys")
+	}
+	if _, err = UnSerialize(bytes.NewReader(unsigned.Bytes())); err == nil {
+		t.Error("unsigned bundle is accepted with trusted keys")
+	}
+	if _, err = UnSerialize(bytes.NewReader(signed.Bytes())); err != nil {
+		t.Errorf("signed bundle with trusted keys: %v", err)
+	}
+	tampered := append([]byte(nil), signed.Bytes()...)
+	tampered[len(tampered)/2] ^= 0xff
+	if _, err = UnSerialize(bytes.NewReader(tampered)); err == nil {
+		t.Error("tampered bundle is accepted with trusted keys")
+	}
+
+	SetTrustedKeys([]ed25519.PublicKey{otherKey})
+	if _, err = UnSerialize(bytes.NewReader(signed.Bytes())); err == nil {
+		t.Error("bundle signed by an untrusted key is accepted")
+	}
+}
@@ -0,0 +1,87 @@
+package link
+
+import (
+	"github.com/pkujhd/goloader/constants"
+)
+
+// symbolCounts counts the symbols which a module adds to runtime
+type symbolCounts struct {
+	funcs     int
+	types     int
+	itabs     int
+	fakeItabs int
+}
+
+// ModuleStats describes the memory held by a loaded module, sizes are in bytes
+type ModuleStats struct {
+	Code              int // text of functions, including far address epilogues and padding
+	Veneers           int // veneers of far calls laid out after the last function
+	Data              int
+	NoPtrTypeData     int
+	NoPtrItabData     int
+	NoPtrData         int
+	Pclntab           int
+	Bss               int
+	NoPtrBss          int
+	ExtraDataReserved int // GOT slots and far addresses reserved after the sections of data segment
+	ExtraDataUsed     int
+	Strings           int // string constants allocated on heap
+	StringBytes       int
+	Funcs             int
+	Types             int
+	Itabs             int
+	FakeItabs         int // itabs added for methods which are dead in host
+	CodeMapped        int // mapped, or allocated from the arena of module
+	CodeUsed          int
+	DataMapped        int
+	DataUsed          int
+}
+
+// countSymbols counts the functions, types and itabs defined by objs of module
+func (linker *Linker) countSymbols(codeModule *CodeModule) {
+	codeModule.funcs = len(linker.Funcs)
+	for name, sym := range linker.SymMap {
+		if sym.Offset == constants.InvalidOffset {
+			continue
+		}
+		if isTypeName(name) {
+			codeModule.types++
+		} else if isItabName(name) {
+			codeModule.itabs++
+		}
+	}
+}
+
+// Stats returns the memory held by module, mapped and used bytes of segments are 0 after it is unloaded
+func (cm *CodeModule) Stats() ModuleStats {
+	stats := ModuleStats{
+		Code:              cm.codeSeg.length,
+		Veneers:           cm.veneerLen,
+		Data:              cm.dataLen,
+		NoPtrTypeData:     cm.noPtrTypeDataLen,
+		NoPtrItabData:     cm.noPtrItabDataLen,
+		NoPtrData:         cm.noPtrDataLen,
+		Pclntab:           cm.pclntabLen,
+		Bss:               cm.bssLen,
+		NoPtrBss:          cm.noPtrBssLen,
+		ExtraDataReserved: cm.extraDataLen,
+		Funcs:             cm.funcs,
+		Types:             cm.types,
+		Itabs:             cm.itabs,
+		FakeItabs:         cm.fakeItabs,
+	}
+	for _, str := range cm.stringMap {
+		stats.Strings++
+		stats.StringBytes += len(*str)
+	}
+	if cm.codeByte != nil {
+		stats.CodeMapped = cap(cm.codeByte)
+		stats.CodeUsed = cm.codeOff
+	}
+	if cm.dataByte != nil {
+		stats.DataMapped = cap(cm.dataByte)
+		stats.DataUsed = cm.dataOff
+		stats.ExtraDataUsed = cm.dataOff - cm.dataSeg.length
+	}
+	return stats
+}
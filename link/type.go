@@ -108,6 +108,12 @@ func rtypeOf(i reflect.Type) *_type {
 	return (*_type)(eface.word)
 }
 
+func toReflectType(typ *_type) reflect.Type {
+	t := reflect.TypeOf(0)
+	(*emptyInterface)(unsafe.Pointer(&t)).word = unsafe.Pointer(typ)
+	return t
+}
+
 func resolveTypeName(typ *_type) string {
 	pkgPath := obj.PathToPrefix(typ.PkgPath())
 	name := typ.Name()
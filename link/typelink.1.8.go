@@ -18,7 +18,8 @@ func typelinksRegister(symPtr map[string]uintptr) {
 
 func (linker *Linker) AddTypeLink(codeModule *CodeModule) {
 	module := codeModule.module
-	for name, symbol := range linker.SymMap {
+	for _, name := range sortedKeys(linker.SymMap) {
+		symbol := linker.SymMap[name]
 		if isTypeName(name) && symbol.Offset != constants.InvalidOffset {
 			typeOff := int32(codeModule.dataBase + symbol.Offset - int(module.types))
 			module.typelinks = append(module.typelinks, typeOff)
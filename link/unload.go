@@ -0,0 +1,102 @@
+package link
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// goroutineRecords returns the stacks of all goroutines, each stack has at most len(StackRecord.Stack0) frames
+func goroutineRecords() []runtime.StackRecord {
+	n, _ := runtime.GoroutineProfile(nil)
+	for {
+		records := make([]runtime.StackRecord, n+10)
+		var ok bool
+		if n, ok = runtime.GoroutineProfile(records); ok {
+			return records[:n]
+		}
+	}
+}
+
+// mayBeUsed reports whether any goroutine may have a frame in the text of module, stacks truncated by
+// GoroutineProfile may have frames of module beyond their last frame
+func (cm *CodeModule) mayBeUsed() bool {
+	start, end := uintptr(cm.codeBase), uintptr(cm.codeBase+len(cm.codeByte))
+	for _, record := range goroutineRecords() {
+		stack := record.Stack()
+		if len(stack) == len(record.Stack0) {
+			return true
+		}
+		for _, pc := range stack {
+			// pc of a caller frame is the return address, it may be the end of function
+			if pc-1 >= start && pc-1 < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usedGoroutines returns the goroutines which have a frame in the text of module, the entry of every frame is
+// compared with the code segment, so frames are found whatever their names are.
+// complete stacks and ids of goroutines are read from a heap dump, which stops the world while it is written,
+// so it is only dumped if GoroutineProfile finds a goroutine which may be in module
+func (cm *CodeModule) usedGoroutines() ([]string, error) {
+	if !cm.mayBeUsed() {
+		return nil, nil
+	}
+	dump, err := cm.readHeapDump(true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("read goroutines from heap dump error: %v", err)
+	}
+	start, end := uint64(cm.codeBase), uint64(cm.codeBase+len(cm.codeByte))
+	goroutines := make([]string, 0)
+	for _, goroutine := range dump.goroutines {
+		for _, frame := range goroutine.frames {
+			if frame.entry >= start && frame.entry < end {
+				goroutines = append(goroutines, fmt.Sprintf("goroutine %d [%s] in %s at 0x%x",
+					goroutine.id, goroutine.waitReason, frame.name, frame.pc))
+				break
+			}
+		}
+	}
+	return goroutines, nil
+}
+
+// InUse reports whether any goroutine is still executing code of module,
+// module is reported in use if the stacks of goroutines can't be read
+func (cm *CodeModule) InUse() bool {
+	goroutines, err := cm.usedGoroutines()
+	return err != nil || len(goroutines) > 0
+}
+
+// UnloadSafe unloads module only if no goroutine is executing code of module and no loaded module imports it,
+// otherwise returns an error listing the goroutines or dependents and leaves module loaded.
+// pending timers and closures of module held by host are only detected by DanglingPointers,
+// which is called when Linker.CheckDanglingPointers is set, module is not unloaded if any pointer is reported
+func (cm *CodeModule) UnloadSafe() error {
+	if err := cm.checkDependents(); err != nil {
+		return err
+	}
+	goroutines, err := cm.usedGoroutines()
+	if err != nil {
+		return err
+	}
+	if len(goroutines) > 0 {
+		return fmt.Errorf("module is still in use by %d goroutine(s):\n%s", len(goroutines), strings.Join(goroutines, "\n"))
+	}
+	if cm.checkDanglingPointers {
+		pointers, err := cm.DanglingPointers()
+		if err != nil {
+			return fmt.Errorf("scan dangling pointers error: %v", err)
+		}
+		if len(pointers) > 0 {
+			holders := make([]string, len(pointers))
+			for i, pointer := range pointers {
+				holders[i] = pointer.String()
+			}
+			return fmt.Errorf("module is still referenced by %d pointer(s) of host:\n%s", len(pointers), strings.Join(holders, "\n"))
+		}
+	}
+	return cm.Unload()
+}
@@ -4,7 +4,9 @@ import (
 	"cmd/objfile/sys"
 	"encoding/binary"
 	"fmt"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"unsafe"
@@ -75,6 +77,10 @@ func createArchNops(arch *sys.Arch, size int) []byte {
 		return createArm64Nops(size)
 	case sys.Arch386.Name, sys.ArchAMD64.Name:
 		return createX86Amd64Nops(size)
+	case archNameRISCV64:
+		return createRISCV64Nops(size)
+	case archNameLOONG64:
+		return createLOONG64Nops(size)
 	default:
 		panic(fmt.Errorf("not support arch:%s", arch.Name))
 	}
@@ -116,6 +122,13 @@ func putAddress(byteOrder binary.ByteOrder, b []byte, addr uint64) {
 	}
 }
 
+func getAddress(byteOrder binary.ByteOrder, b []byte) uint64 {
+	if constants.PtrSize == constants.Uint32Size {
+		return uint64(byteOrder.Uint32(b))
+	}
+	return byteOrder.Uint64(b)
+}
+
 // sign extend a 24-bit integer
 func signext24(x int64) int32 {
 	return (int32(x) << 8) >> 8
@@ -166,6 +179,10 @@ func Mmap(size int) ([]byte, error) {
 	return mmap.Mmap(size)
 }
 
+func MmapCode(size int) ([]byte, error) {
+	return mmap.MmapCode(size)
+}
+
 func MmapData(size int) ([]byte, error) {
 	return mmap.MmapData(size)
 }
@@ -235,6 +252,11 @@ func isMmapInLowAddress(archName string) bool {
 	return false
 }
 
+// isInLowAddress reports whether b is inside the low 2GB address space used by MAP_32BIT
+func isInLowAddress(b []byte) bool {
+	return uintptr(unsafe.Pointer(&b[0]))+uintptr(len(b)) <= 1<<31
+}
+
 //go:inline
 func isX86_64(archName string) bool {
 	return archName == sys.ArchAMD64.Name || archName == sys.Arch386.Name
@@ -271,3 +293,13 @@ func gostringnocopy(str *byte) string {
 	s := *(*string)(unsafe.Pointer(&ss))
 	return s
 }
+
+// sortedKeys returns the keys of a map with string keys in order, for deterministic layout
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
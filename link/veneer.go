@@ -0,0 +1,211 @@
+package link
+
+import (
+	"cmd/objfile/sys"
+	"fmt"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+	"github.com/pkujhd/goloader/objabi/symkind"
+)
+
+// a call which overflows jumps to the veneer of its target instead of an epilogue of its own,
+// veneers are laid out as the last function, so the runtime can find and unwind a pc in a veneer.
+// on x86/amd64 a veneer jumps through the GOT slot of its target, on arm64, riscv64 and loong64 the address is inline.
+// every target has one veneer and one GOT slot, no matter how many times it is called
+const (
+	veneerAlign       = 16
+	veneerSizeAMD64   = 8  // JMPL *slot(rip), 2 NOPs
+	veneerSizeARM64   = 16 // LDR X27 [PC+8], BR X27, address
+	veneerSizeRISCV64 = 24 // AUIPC X31, LD X31 16(X31), JALR X31, NOP, address
+	veneerSizeLOONG64 = 24 // PCADDU12I R30, LD.D R30 R30 16, JIRL R30, NOP, address
+	veneerUnsupported = 0
+	veneerSymName     = "goloader.veneers"
+)
+
+func veneerSize(arch *sys.Arch) int {
+	switch arch.Family {
+	case sys.AMD64:
+		return veneerSizeAMD64
+	case sys.ARM64:
+		return veneerSizeARM64
+	}
+	if isRISCV64(arch.Name) {
+		return veneerSizeRISCV64
+	}
+	if isLOONG64(arch.Name) {
+		return veneerSizeLOONG64
+	}
+	return veneerUnsupported
+}
+
+// farTargetKey names the target of loc in veneer and GOT tables
+func farTargetKey(loc obj.Reloc) string {
+	if loc.Add == 0 {
+		return loc.SymName
+	}
+	return fmt.Sprintf("%s%+d", loc.SymName, loc.Add)
+}
+
+// useVeneer reports whether an overflowed loc is relocated to a veneer instead of an epilogue
+func useVeneer(arch *sys.Arch, loc obj.Reloc) bool {
+	if veneerSize(arch) == veneerUnsupported {
+		return false
+	}
+	switch loc.Type &^ reloctype.R_WEAK {
+	case reloctype.R_CALL:
+		if arch.Family != sys.AMD64 {
+			return false
+		}
+		switch obj.GetOpName(loc.Op) {
+		case "CALL", "JMP":
+			return true
+		}
+	case reloctype.R_CALLARM64:
+		return true
+	case reloctype.R_RISCV_JAL, reloctype.R_RISCV_CALL:
+		return isRISCV64(arch.Name)
+	case reloctype.R_CALLLOONG64:
+		return isLOONG64(arch.Name)
+	}
+	return false
+}
+
+// addGOTSlot reserves a GOT slot for the target of loc in data segment, if it has none
+func (linker *Linker) addGOTSlot(loc obj.Reloc) {
+	key := farTargetKey(loc)
+	if _, ok := linker.GOTSlots[key]; !ok {
+		linker.GOTSlots[key] = len(linker.GOTSlots)
+		linker.ExtraData += constants.PtrSize
+	}
+}
+
+// addVeneer reserves a veneer for the target of loc, and the GOT slot used by it on x86/amd64
+func (linker *Linker) addVeneer(loc obj.Reloc) {
+	key := farTargetKey(loc)
+	if _, ok := linker.Veneers[key]; !ok {
+		linker.Veneers[key] = len(linker.Veneers)
+	}
+	if linker.Arch.Family == sys.AMD64 {
+		linker.addGOTSlot(loc)
+	}
+}
+
+// addVeneerSymbol adds the veneer table as a function without frame after the functions of linker,
+// so it is inside the text range of module and has pcsp data like the trampolines of linker
+func (linker *Linker) addVeneerSymbol() error {
+	if linker.AdaptedOffset || len(linker.Veneers) == 0 {
+		return nil
+	}
+	if _, ok := linker.SymMap[veneerSymName]; ok {
+		return nil
+	}
+	size := len(linker.Veneers) * veneerSize(linker.Arch)
+	pcsp := writePCValue(make([]byte, 0), 1, uint64(size/linker.Arch.MinLC))
+	pcsp = append(pcsp, 0)
+	if linker.ObjSymbolMap == nil {
+		linker.ObjSymbolMap = make(map[string]*obj.ObjSymbol)
+		defer func() { linker.ObjSymbolMap = nil }()
+	}
+	linker.ObjSymbolMap[veneerSymName] = &obj.ObjSymbol{
+		Name: veneerSymName,
+		Kind: symkind.STEXT,
+		Size: int64(size),
+		Data: createArchNops(linker.Arch, size),
+		Func: &obj.FuncInfo{PCSP: pcsp},
+	}
+	bytearrayAlignNops(linker.Arch, &linker.Code, veneerAlign)
+	_, err := linker.addSymbol(veneerSymName, nil)
+	return err
+}
+
+// layoutVeneers finds the veneer table in code segment, and returns the length of code segment
+func layoutVeneers(linker *Linker, codeModule *CodeModule) int {
+	codeSeg := &codeModule.segment.codeSeg
+	if symbol, ok := linker.SymMap[veneerSymName]; ok {
+		codeSeg.veneerOff = symbol.Offset
+		codeSeg.veneerLen = len(linker.Veneers) * veneerSize(linker.Arch)
+	}
+	return len(linker.Code)
+}
+
+// gotSlot writes addr into the GOT slot of the target of loc, and returns the address of the slot
+func (linker *Linker) gotSlot(segment *segment, loc obj.Reloc, addr uintptr) (uintptr, bool) {
+	index, ok := linker.GOTSlots[farTargetKey(loc)]
+	if !ok {
+		return 0, false
+	}
+	off := segment.gotOff + index*constants.PtrSize
+	slot := uintptr(segment.dataBase + off)
+	if getAddress(linker.Arch.ByteOrder, segment.dataByte[off:]) != uint64(addr) {
+		linker.notifyGOTSlot(farTargetKey(loc), addr, slot)
+		putAddress(linker.Arch.ByteOrder, segment.dataByte[off:], uint64(addr))
+	}
+	return slot, true
+}
+
+// veneer writes the veneer of the target of loc which jumps to addr, and returns its offset in code segment
+func (linker *Linker) veneer(segment *segment, loc obj.Reloc, addr uintptr) (int, uintptr, error) {
+	index, ok := linker.Veneers[farTargetKey(loc)]
+	if !ok {
+		return 0, 0, fmt.Errorf("no veneer is reserved for %s", farTargetKey(loc))
+	}
+	byteOrder := linker.Arch.ByteOrder
+	off := segment.veneerOff + index*veneerSize(linker.Arch)
+	code := segment.codeByte[off : off+veneerSize(linker.Arch)]
+	switch linker.Arch.Family {
+	case sys.AMD64:
+		slot, ok := linker.gotSlot(segment, loc, addr)
+		if !ok {
+			return 0, 0, fmt.Errorf("no got slot is reserved for %s", farTargetKey(loc))
+		}
+		copy(code, x86amd64JMPLCode)
+		byteOrder.PutUint32(code[2:], uint32(int(slot)-(segment.codeBase+off+len(x86amd64JMPLCode))))
+		copy(code[len(x86amd64JMPLCode):], createArchNops(linker.Arch, len(code)-len(x86amd64JMPLCode)))
+		return off, slot, nil
+	case sys.ARM64:
+		copy(code, arm64ReplaceCALLCode)
+		putAddress(byteOrder, code[len(arm64ReplaceCALLCode):], uint64(addr))
+		return off, 0, nil
+	}
+	if isRISCV64(linker.Arch.Name) {
+		copy(code, riscv64ReplaceCALLCode)
+		putAddress(byteOrder, code[len(riscv64ReplaceCALLCode):], uint64(addr))
+		return off, 0, nil
+	}
+	if isLOONG64(linker.Arch.Name) {
+		copy(code, loong64ReplaceCALLCode)
+		putAddress(byteOrder, code[len(loong64ReplaceCALLCode):], uint64(addr))
+		return off, 0, nil
+	}
+	return 0, 0, fmt.Errorf("veneer is not supported on %s", linker.Arch.Name)
+}
+
+// veneerTarget decodes the address which the veneer at addr jumps to
+func (cm *CodeModule) veneerTarget(addr uintptr) (uintptr, bool) {
+	size := veneerSize(cm.arch)
+	off := int(addr) - cm.codeBase - cm.veneerOff
+	if size == veneerUnsupported || off < 0 || off%size != 0 || cm.veneerOff+off+size > len(cm.codeByte) {
+		return 0, false
+	}
+	code := cm.codeByte[cm.veneerOff+off:]
+	switch cm.arch.Family {
+	case sys.AMD64:
+		rel := int32(cm.arch.ByteOrder.Uint32(code[2:]))
+		slot := int(addr) + len(x86amd64JMPLCode) + int(rel) - cm.dataBase
+		if slot < 0 || slot+constants.PtrSize > len(cm.dataByte) {
+			return 0, false
+		}
+		return uintptr(getAddress(cm.arch.ByteOrder, cm.dataByte[slot:])), true
+	case sys.ARM64:
+		return uintptr(getAddress(cm.arch.ByteOrder, code[len(arm64ReplaceCALLCode):])), true
+	}
+	if isRISCV64(cm.arch.Name) {
+		return uintptr(getAddress(cm.arch.ByteOrder, code[len(riscv64ReplaceCALLCode):])), true
+	}
+	if isLOONG64(cm.arch.Name) {
+		return uintptr(getAddress(cm.arch.ByteOrder, code[len(loong64ReplaceCALLCode):])), true
+	}
+	return 0, false
+}
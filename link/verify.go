@@ -0,0 +1,188 @@
+package link
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/obj"
+	"github.com/pkujhd/goloader/objabi/reloctype"
+	"github.com/pkujhd/goloader/objabi/symkind"
+)
+
+// decodePCTable decodes a pcvalue table, re-encodes it and compares with p,
+// it returns the last pc covered by the table
+func decodePCTable(p []byte, pcQuantum uintptr) (uintptr, error) {
+	pc := uintptr(0)
+	encoded := make([]byte, 0)
+	for n, first := 0, true; ; first = false {
+		uvdelta, size := binary.Uvarint(p[n:])
+		if size <= 0 {
+			return 0, fmt.Errorf("truncated value at %d", n)
+		}
+		if uvdelta == 0 && !first {
+			encoded = append(encoded, 0)
+			n += size
+			if !bytes.Equal(encoded, p[:n]) {
+				return 0, fmt.Errorf("table does not round-trip")
+			}
+			return pc, nil
+		}
+		valDelta, _ := binary.Varint(p[n:])
+		n += size
+		pcDelta, size := binary.Uvarint(p[n:])
+		if size <= 0 {
+			return 0, fmt.Errorf("truncated pc at %d", n)
+		}
+		n += size
+		pc += uintptr(pcDelta) * pcQuantum
+		encoded = writePCValue(encoded, valDelta, pcDelta)
+	}
+}
+
+// verifyPCTables checks that pcsp, pcfile and pcline of every function decode, round-trip,
+// and cover the relocations and epilogues of the function
+func (linker *Linker) verifyPCTables(codeModule *CodeModule, symbolMap map[string]uintptr) error {
+	pcQuantum := getPCQuantum(linker.Arch)
+	for _, _func := range linker.Funcs {
+		name := getfuncname(_func, codeModule.module)
+		entryOff := int(symbolMap[name]) - codeModule.codeBase
+		end := 0
+		for _, loc := range linker.SymMap[name].Reloc {
+			if loc.Size > 0 && loc.Offset+loc.Size-entryOff > end {
+				end = loc.Offset + loc.Size - entryOff
+			}
+			if loc.Epilogue.Size > 0 && loc.Epilogue.Offset+loc.Epilogue.Size-entryOff > end {
+				end = loc.Epilogue.Offset + loc.Epilogue.Size - entryOff
+			}
+		}
+		tables := []struct {
+			name string
+			off  int
+		}{{"pcsp", int(_func.Pcsp)}, {"pcfile", int(_func.Pcfile)}, {"pcline", int(_func.Pcln)}}
+		for _, table := range tables {
+			// like runtime, offset 0 of pcfile and pcline means the table is absent, e.g. for veneers
+			if table.off == 0 && table.name != "pcsp" {
+				continue
+			}
+			if table.off <= 0 || table.off >= len(linker.Pclntable) {
+				return fmt.Errorf("func %s: %s offset %d is out of pclntable", name, table.name, table.off)
+			}
+			lastPC, err := decodePCTable(linker.Pclntable[table.off:], pcQuantum)
+			if err != nil {
+				return fmt.Errorf("func %s: %s %v", name, table.name, err)
+			}
+			if int(lastPC) < end {
+				return fmt.Errorf("func %s: %s covers %#x bytes, but relocations and epilogues end at %#x", name, table.name, lastPC, end)
+			}
+		}
+	}
+	return nil
+}
+
+// isFuncEntry reports whether addr is the entry of a function of host or any loaded module
+func isFuncEntry(addr uintptr) bool {
+	f := runtime.FuncForPC(addr)
+	return f != nil && f.Entry() == addr
+}
+
+// callTarget decodes the target of a relocated call or jump instruction
+func (linker *Linker) callTarget(codeModule *CodeModule, loc obj.Reloc) (uintptr, bool) {
+	byteOrder := linker.Arch.ByteOrder
+	switch loc.Type &^ reloctype.R_WEAK {
+	case reloctype.R_CALL:
+		rel := int32(byteOrder.Uint32(codeModule.codeByte[loc.Offset:]))
+		return uintptr(codeModule.codeBase + loc.Offset + loc.Size + int(rel)), true
+	case reloctype.R_CALLARM64:
+		imm := int32(byteOrder.Uint32(codeModule.codeByte[loc.Offset:])<<6) >> 6
+		return uintptr(codeModule.codeBase + loc.Offset + int(imm)*4), true
+	case reloctype.R_RISCV_JAL:
+		jal := byteOrder.Uint32(codeModule.codeByte[loc.Offset:])
+		imm := int32(jal&0x80000000)>>11 | int32(jal&0xFF000) | int32(jal>>9&0x800) | int32(jal>>20&0x7FE)
+		return uintptr(codeModule.codeBase + loc.Offset + int(imm)), true
+	case reloctype.R_CALLLOONG64:
+		bl := byteOrder.Uint32(codeModule.codeByte[loc.Offset:])
+		imm := int32(bl<<22)>>6 | int32(bl>>10&0xFFFF)
+		return uintptr(codeModule.codeBase + loc.Offset + int(imm)*4), true
+	case reloctype.R_RISCV_CALL:
+		auipc := int32(byteOrder.Uint32(codeModule.codeByte[loc.Offset:]) &^ 0xFFF)
+		jalr := int32(byteOrder.Uint32(codeModule.codeByte[loc.Offset+4:])) >> 20
+		return uintptr(codeModule.codeBase + loc.Offset + int(auipc) + int(jalr)), true
+	}
+	return 0, false
+}
+
+// inSegments reports whether addr is inside the code or data segment of module
+func inSegments(codeModule *CodeModule, addr uintptr) bool {
+	segment := &codeModule.segment
+	return (addr >= uintptr(segment.codeBase) && addr < uintptr(segment.codeBase+segment.codeSeg.maxLen)) ||
+		(addr >= uintptr(segment.dataBase) && addr < uintptr(segment.dataBase+segment.dataSeg.maxLen))
+}
+
+// isHostSymbol reports whether addr is the address of a symbol in symPtr, which also holds the symbols of imported modules
+func isHostSymbol(symPtr map[string]uintptr, name string, addr uintptr) bool {
+	ptr, ok := symPtr[name]
+	return ok && ptr == addr
+}
+
+// verify checks the relocated module before its code is executable:
+// every call lands at a function entry, at the epilogue trampoline of its relocation or at the veneer of its target,
+// every R_ADDR points into the segments of module or at a host symbol,
+// and the pc tables of every function round-trip
+func (linker *Linker) verify(codeModule *CodeModule, symbolMap, symPtr map[string]uintptr) error {
+	segment := &codeModule.segment
+	for _, name := range sortedKeys(linker.SymMap) {
+		symbol := linker.SymMap[name]
+		if symbol.Offset == constants.InvalidOffset {
+			continue
+		}
+		relocByte := segment.dataByte
+		if symkind.IsText(symbol.Kind) {
+			relocByte = segment.codeByte
+		}
+		interfaceTypeMap := getUseInterfaceTypeMap(symbol)
+		for _, loc := range symbol.Reloc {
+			if _, ok := symbolMap[loc.SymName]; !ok {
+				continue
+			}
+			base := linker.relocSymbolAddr(loc, interfaceTypeMap, symbolMap, segment)
+			if base == constants.InvalidHandleValue {
+				continue
+			}
+			expected := uintptr(int(base) + loc.Add)
+			if symkind.IsText(symbol.Kind) {
+				if target, ok := linker.callTarget(codeModule, loc); ok {
+					epilogue := uintptr(segment.codeBase + loc.Epilogue.Offset)
+					veneerTarget, isVeneer := codeModule.veneerTarget(target)
+					switch {
+					case loc.Epilogue.Size > 0 && target == epilogue:
+					case isVeneer && veneerTarget != expected:
+						return fmt.Errorf("verify %s: call at %#x lands at veneer %#x to %#x, expect %s(%#x)", name, loc.Offset, target, veneerTarget, loc.SymName, expected)
+					case isVeneer:
+					case target != expected:
+						return fmt.Errorf("verify %s: call at %#x lands at %#x, expect %s(%#x)", name, loc.Offset, target, loc.SymName, expected)
+					case !isFuncEntry(target) && linker.CgoImportMap[loc.SymName] == nil:
+						return fmt.Errorf("verify %s: call at %#x lands at %#x, which is not a function entry of %s", name, loc.Offset, target, loc.SymName)
+					}
+				}
+			}
+			if loc.Type == reloctype.R_ADDR {
+				var value uintptr
+				if constants.PtrSize == constants.Uint32Size {
+					value = uintptr(linker.Arch.ByteOrder.Uint32(relocByte[loc.Offset:]))
+				} else {
+					value = uintptr(linker.Arch.ByteOrder.Uint64(relocByte[loc.Offset:]))
+				}
+				if value != expected {
+					return fmt.Errorf("verify %s: R_ADDR at %#x is %#x, expect %s(%#x)", name, loc.Offset, value, loc.SymName, expected)
+				}
+				if !inSegments(codeModule, base) && !isHostSymbol(symPtr, loc.SymName, base) && !isStringTypeName(loc.SymName) {
+					return fmt.Errorf("verify %s: R_ADDR at %#x points to %s(%#x), which is neither in module nor a host symbol", name, loc.Offset, loc.SymName, base)
+				}
+			}
+		}
+	}
+	return linker.verifyPCTables(codeModule, symbolMap)
+}
@@ -0,0 +1,50 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package link
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var verifyTestFiles = map[string]string{
+	"p.go": `package verifytest
+
+import "fmt"
+
+var counter int
+
+//go:noinline
+func Store(v int) {
+	counter = v
+}
+
+func Run() {
+	Store(42)
+	fmt.Println("verified", counter)
+}
+`,
+}
+
+// RegSymbol can't read the symbols of a test binary, so objs are loaded by examples/loader.
+// calls to host, such as fmt.Println, have veneers, so module has the function of veneers,
+// which has no pcfile and pcline tables
+func TestLoadVerify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("objs are built for linux")
+	}
+	archive := buildCrossObjs(t, runtime.GOARCH, "verifytest", verifyTestFiles)
+	loader := filepath.Join(t.TempDir(), "loader")
+	build := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), "build", "-o", loader, "../examples/loader")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build loader: %v\n%s", err, output)
+	}
+	output, err := exec.Command(loader, "-verify", "-o", archive+":verifytest", "-run", "verifytest.Run").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(output)) != "verified 42" {
+		t.Errorf("loader -verify: %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.12
+// +build go1.12
+
+package link
+
+import (
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkujhd/goloader/constants"
+)
+
+func goloaderVersion() string {
+	version := develVersion
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == goloaderPath {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == goloaderPath {
+				version = dep.Version
+				if dep.Replace != nil {
+					version = dep.Replace.Version
+				}
+			}
+		}
+	}
+	// a local or modified source tree has no release version
+	if version == constants.EmptyString || strings.HasSuffix(version, dirtySuffix) {
+		return develVersion
+	}
+	return version
+}
@@ -0,0 +1,8 @@
+//go:build go1.8 && !go1.12
+// +build go1.8,!go1.12
+
+package link
+
+func goloaderVersion() string {
+	return develVersion
+}
@@ -0,0 +1,16 @@
+//go:build go1.18
+// +build go1.18
+
+package goloader
+
+import "reflect"
+
+// Lookup returns the function or global variable name of codeModule as T,
+// T is a func type for functions and a pointer type for variables
+func Lookup[T any](codeModule *CodeModule, name string) (T, error) {
+	var value T
+	if reflect.TypeOf(&value).Elem().Kind() == reflect.Func {
+		return value, codeModule.Func(name, &value)
+	}
+	return value, codeModule.Var(name, &value)
+}
@@ -0,0 +1,16 @@
+package mmap
+
+import "sync/atomic"
+
+// lowAddressExhausted is set once a mapping in the low address space fails on linux/amd64
+var lowAddressExhausted int32
+
+// LowAddressExhausted reports whether the low 2GB address space used by MAP_32BIT on linux/amd64 is exhausted,
+// the segments mapped after that are placed anywhere. it is always false on other platforms
+func LowAddressExhausted() bool {
+	return atomic.LoadInt32(&lowAddressExhausted) != 0
+}
+
+func setLowAddressExhausted() {
+	atomic.StoreInt32(&lowAddressExhausted, 1)
+}
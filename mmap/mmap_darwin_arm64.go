@@ -29,6 +29,13 @@ func MakeThreadJITCodeExecutable(ptr uintptr, len int) {
 	C.cache_invalidate(unsafe.Pointer(ptr), C.size_t(len))
 }
 
+// MAP_JIT code is always writable and executable, pthread_jit_write_protect_np switches it per thread
+const defaultWriteXorExecute = false
+
+func MmapCode(size int) ([]byte, error) {
+	return Mmap(size)
+}
+
 func Mmap(size int) ([]byte, error) {
 	data, err := syscall.Mmap(
 		0,
@@ -11,26 +11,52 @@ import (
 func MakeThreadJITCodeExecutable(ptr uintptr, len int) {
 }
 
+const defaultWriteXorExecute = true
+
 func Mmap(size int) ([]byte, error) {
-	data, err := syscall.Mmap(
-		0,
-		0,
-		size,
-		syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC,
-		syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_32BIT)
-	if err != nil {
-		err = os.NewSyscallError("syscall.Mmap", err)
+	return mmapCode(size, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC)
+}
+
+// MmapCode maps a code segment, it is not executable until ProtectCode if WriteXorExecute is set
+func MmapCode(size int) ([]byte, error) {
+	if WriteXorExecute {
+		return mmapCode(size, syscall.PROT_READ|syscall.PROT_WRITE)
 	}
-	return data, err
+	return Mmap(size)
+}
+
+func mmapCode(size int, prot int) ([]byte, error) {
+	return mmapLow(size, prot)
 }
 
 func MmapData(size int) ([]byte, error) {
+	return mmapLow(size, syscall.PROT_READ|syscall.PROT_WRITE)
+}
+
+// mmapLow maps in the low 2GB address space with MAP_32BIT, once it is exhausted,
+// it sets LowAddressExhausted and maps anywhere
+func mmapLow(size int, prot int) ([]byte, error) {
+	if !LowAddressExhausted() {
+		data, err := syscall.Mmap(
+			0,
+			0,
+			size,
+			prot,
+			syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_32BIT)
+		if err != syscall.ENOMEM {
+			if err != nil {
+				err = os.NewSyscallError("syscall.Mmap", err)
+			}
+			return data, err
+		}
+		setLowAddressExhausted()
+	}
 	data, err := syscall.Mmap(
 		0,
 		0,
 		size,
-		syscall.PROT_READ|syscall.PROT_WRITE,
-		syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_32BIT)
+		prot,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON)
 	if err != nil {
 		err = os.NewSyscallError("syscall.Mmap", err)
 	}
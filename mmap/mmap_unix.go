@@ -11,12 +11,26 @@ import (
 func MakeThreadJITCodeExecutable(ptr uintptr, len int) {
 }
 
+const defaultWriteXorExecute = ProtectSupported
+
 func Mmap(size int) ([]byte, error) {
+	return mmapCode(size, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC)
+}
+
+// MmapCode maps a code segment, it is not executable until ProtectCode if WriteXorExecute is set
+func MmapCode(size int) ([]byte, error) {
+	if WriteXorExecute {
+		return mmapCode(size, syscall.PROT_READ|syscall.PROT_WRITE)
+	}
+	return Mmap(size)
+}
+
+func mmapCode(size int, prot int) ([]byte, error) {
 	data, err := syscall.Mmap(
 		0,
 		0,
 		size,
-		syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC,
+		prot,
 		syscall.MAP_PRIVATE|syscall.MAP_ANON)
 	if err != nil {
 		err = os.NewSyscallError("syscall.Mmap", err)
@@ -38,6 +52,9 @@ func MmapData(size int) ([]byte, error) {
 }
 
 func Munmap(b []byte) (err error) {
+	if ok, err := munmapNear(b); ok {
+		return err
+	}
 	err = syscall.Munmap(b)
 	if err != nil {
 		err = os.NewSyscallError("syscall.Munmap", err)
@@ -19,6 +19,21 @@ type sliceHeader struct {
 func MakeThreadJITCodeExecutable(ptr uintptr, len int) {
 }
 
+const defaultWriteXorExecute = true
+
+// MmapCode maps a code segment, it is not executable until ProtectCode if WriteXorExecute is set.
+// the view is mapped with FILE_MAP_EXECUTE, so its protection can be changed to PAGE_EXECUTE_READ later
+func MmapCode(size int) ([]byte, error) {
+	b, err := Mmap(size)
+	if err == nil && WriteXorExecute {
+		if err = protect(b, syscall.PAGE_READWRITE); err != nil {
+			_ = Munmap(b)
+			return nil, err
+		}
+	}
+	return b, err
+}
+
 func Mmap(size int) ([]byte, error) {
 
 	sizelo := uint32(size >> 32)
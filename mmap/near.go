@@ -0,0 +1,49 @@
+package mmap
+
+// PlaceNearHost places segments mapped by MmapCodeNear and MmapDataNear within the distance of the host,
+// so relocations to the host don't overflow. it is supported on linux/arm64, linux/riscv64 and linux/loong64,
+// linux/amd64 maps segments in the low address space instead. set it false before Load to disable it
+var PlaceNearHost = true
+
+// Near is a range of addresses, a mapping placed near it is within Distance of every address in [Start, End)
+type Near struct {
+	Start    uintptr
+	End      uintptr
+	Distance uintptr
+}
+
+// Contains reports whether [addr, addr+size) is within Distance of every address in near, it is false if near is empty
+func (near Near) Contains(addr, size uintptr) bool {
+	if near.End <= near.Start || addr+size <= addr {
+		return false
+	}
+	low, high := near.Start, near.End
+	if addr < low {
+		low = addr
+	}
+	if addr+size > high {
+		high = addr + size
+	}
+	return high-low <= near.Distance
+}
+
+// hints returns candidate addresses of a mapping of size near the range, from the nearest to the farthest
+func (near Near) hints(size, pageSize uintptr) []uintptr {
+	hints := make([]uintptr, 0)
+	if near.End <= near.Start || near.End-near.Start >= near.Distance {
+		return hints
+	}
+	for gap := uintptr(0); gap < near.Distance; gap = gap*2 + pageSize*16 {
+		above := (near.End + gap + pageSize - 1) &^ (pageSize - 1)
+		if above >= near.End && near.Contains(above, size) {
+			hints = append(hints, above)
+		}
+		if near.Start > gap+size {
+			below := (near.Start - gap - size) &^ (pageSize - 1)
+			if near.Contains(below, size) {
+				hints = append(hints, below)
+			}
+		}
+	}
+	return hints
+}
@@ -0,0 +1,83 @@
+//go:build linux && (arm64 || riscv64 || loong64)
+// +build linux
+// +build arm64 riscv64 loong64
+
+package mmap
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// _MAP_FIXED_NOREPLACE fails instead of replacing an existing mapping, kernels before 4.17 treat it as a hint
+const _MAP_FIXED_NOREPLACE = 0x100000
+
+// nearMappings holds the mappings placed by hints, syscall.Munmap only unmaps mappings of syscall.Mmap
+var nearMappings = struct {
+	sync.Mutex
+	m map[uintptr]int
+}{m: make(map[uintptr]int)}
+
+func MmapCodeNear(size int, near Near) ([]byte, error) {
+	if WriteXorExecute {
+		return mmapNear(size, syscall.PROT_READ|syscall.PROT_WRITE, near)
+	}
+	return mmapNear(size, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC, near)
+}
+
+func MmapNear(size int, near Near) ([]byte, error) {
+	return mmapNear(size, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC, near)
+}
+
+func MmapDataNear(size int, near Near) ([]byte, error) {
+	return mmapNear(size, syscall.PROT_READ|syscall.PROT_WRITE, near)
+}
+
+// mmapNear probes the hints of near, it maps anywhere if no hint is available
+func mmapNear(size int, prot int, near Near) ([]byte, error) {
+	if PlaceNearHost {
+		for _, hint := range near.hints(uintptr(size), uintptr(os.Getpagesize())) {
+			addr, _, errno := syscall.Syscall6(syscall.SYS_MMAP, hint, uintptr(size), uintptr(prot),
+				syscall.MAP_PRIVATE|syscall.MAP_ANON|_MAP_FIXED_NOREPLACE, ^uintptr(0), 0)
+			if errno != 0 {
+				continue
+			}
+			if !near.Contains(addr, uintptr(size)) {
+				_, _, _ = syscall.Syscall(syscall.SYS_MUNMAP, addr, uintptr(size), 0)
+				continue
+			}
+			nearMappings.Lock()
+			nearMappings.m[addr] = size
+			nearMappings.Unlock()
+			var b []byte
+			header := (*sliceHeader)(unsafe.Pointer(&b))
+			header.Data, header.Len, header.Cap = addr, size, size
+			return b, nil
+		}
+	}
+	return mmapCode(size, prot)
+}
+
+// munmapNear unmaps b if it is placed by hints
+func munmapNear(b []byte) (bool, error) {
+	addr := uintptr(unsafe.Pointer(&b[0]))
+	nearMappings.Lock()
+	size, ok := nearMappings.m[addr]
+	delete(nearMappings.m, addr)
+	nearMappings.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, addr, uintptr(size), 0); errno != 0 {
+		return true, os.NewSyscallError("syscall.Munmap", errno)
+	}
+	return true, nil
+}
+
+type sliceHeader struct {
+	Data uintptr
+	Len  int
+	Cap  int
+}
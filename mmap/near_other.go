@@ -0,0 +1,23 @@
+//go:build !linux || !(arm64 || riscv64 || loong64)
+// +build !linux !arm64,!riscv64,!loong64
+
+package mmap
+
+// MmapCodeNear maps like MmapCode, near is ignored on this platform
+func MmapCodeNear(size int, near Near) ([]byte, error) {
+	return MmapCode(size)
+}
+
+// MmapNear maps like Mmap, near is ignored on this platform
+func MmapNear(size int, near Near) ([]byte, error) {
+	return Mmap(size)
+}
+
+// MmapDataNear maps like MmapData, near is ignored on this platform
+func MmapDataNear(size int, near Near) ([]byte, error) {
+	return MmapData(size)
+}
+
+func munmapNear(b []byte) (bool, error) {
+	return false, nil
+}
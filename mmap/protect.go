@@ -0,0 +1,22 @@
+package mmap
+
+// WriteXorExecute maps code segments writable but not executable, ProtectCode switches them to read+execute
+// after relocation. set it false before loading on platforms which need code both writable and executable.
+// it defaults to false if ProtectSupported is false, setting it then makes ProtectCode return an error
+var WriteXorExecute = defaultWriteXorExecute
+
+// ProtectCode makes a code segment mapped by MmapCode read+execute, it does nothing if WriteXorExecute is not set
+func ProtectCode(b []byte) error {
+	if !WriteXorExecute || len(b) == 0 {
+		return nil
+	}
+	return protect(b, protReadExec)
+}
+
+// ProtectReadOnly makes b read-only, b must start at a page boundary, it does nothing if ProtectSupported is false
+func ProtectReadOnly(b []byte) error {
+	if !ProtectSupported || len(b) == 0 {
+		return nil
+	}
+	return protect(b, protRead)
+}
@@ -0,0 +1,26 @@
+//go:build dragonfly || freebsd || openbsd || netbsd
+// +build dragonfly freebsd openbsd netbsd
+
+package mmap
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ProtectSupported reports whether memory can be protected on this platform
+const ProtectSupported = true
+
+const (
+	protRead     = syscall.PROT_READ
+	protReadExec = syscall.PROT_READ | syscall.PROT_EXEC
+)
+
+func protect(b []byte, prot int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MPROTECT, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(prot))
+	if errno != 0 {
+		return os.NewSyscallError("syscall.Mprotect", errno)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build solaris
+// +build solaris
+
+package mmap
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ProtectSupported reports whether memory can be protected on this platform,
+// syscall has no mprotect on solaris, so code is mapped writable and executable by default
+// and type data, itab data and pclntab stay writable
+const ProtectSupported = false
+
+const (
+	protRead     = syscall.PROT_READ
+	protReadExec = syscall.PROT_READ | syscall.PROT_EXEC
+)
+
+var errProtectUnsupported = errors.New("mprotect is not supported on solaris, set mmap.WriteXorExecute = false")
+
+func protect(b []byte, prot int) error {
+	return errProtectUnsupported
+}
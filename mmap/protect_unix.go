@@ -0,0 +1,24 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package mmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// ProtectSupported reports whether memory can be protected on this platform
+const ProtectSupported = true
+
+const (
+	protRead     = syscall.PROT_READ
+	protReadExec = syscall.PROT_READ | syscall.PROT_EXEC
+)
+
+func protect(b []byte, prot int) error {
+	if err := syscall.Mprotect(b, prot); err != nil {
+		return os.NewSyscallError("syscall.Mprotect", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package mmap
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ProtectSupported reports whether memory can be protected on this platform
+const ProtectSupported = true
+
+const (
+	protRead     = syscall.PAGE_READONLY
+	protReadExec = syscall.PAGE_EXECUTE_READ
+)
+
+var procVirtualProtect = syscall.NewLazyDLL("kernel32.dll").NewProc("VirtualProtect")
+
+func protect(b []byte, prot int) error {
+	var oldProtect uint32
+	r, _, errno := procVirtualProtect.Call(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(prot), uintptr(unsafe.Pointer(&oldProtect)))
+	if r == 0 {
+		return os.NewSyscallError("VirtualProtect", errno)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build go1.23 && !go1.24
+// +build go1.23,!go1.24
+
+package obj
+
+import (
+	"cmd/objfile/objfile"
+	"os"
+)
+
+const disasmPkg = "cmd/objfile/objfile"
+
+func _DummyDisasm(dummy bool) {
+	if dummy {
+		path, _ := os.Executable()
+		f, _ := objfile.Open(path)
+		f.Entries()[0].Disasm()
+	}
+}
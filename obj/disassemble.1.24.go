@@ -0,0 +1,16 @@
+//go:build go1.24 && !go1.28
+// +build go1.24,!go1.28
+
+package obj
+
+import (
+	"cmd/objfile/disasm"
+)
+
+const disasmPkg = "cmd/objfile/disasm"
+
+func _DummyDisasm(dummy bool) {
+	if dummy {
+		_, _ = disasm.DisasmForFile(nil)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build go1.8 && !go1.23
+// +build go1.8,!go1.23
+
+package obj
+
+import (
+	"cmd/objfile/sys"
+	"errors"
+)
+
+func Disassemble(code []byte, pc uint64, arch *sys.Arch, lookup func(addr uint64) (string, uint64)) (text string, size int, err error) {
+	return text, size, errors.New("disassemble needs go1.23 or later")
+}
@@ -0,0 +1,42 @@
+//go:build go1.23 && !go1.28
+// +build go1.23,!go1.28
+
+package obj
+
+import (
+	"cmd/objfile/sys"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+type lookupFunc = func(addr uint64) (sym string, base uint64)
+type disasmFunc func(code []byte, pc uint64, lookup lookupFunc, ord binary.ByteOrder, gnuAsm bool) (text string, size int)
+
+var disasmFuncs = make(map[string]disasmFunc)
+
+// addDisasmLinkName registers the disassemblers of go tool objdump,
+// a missing one only makes Disassemble fail on its arch
+func addDisasmLinkName(symPtr map[string]uintptr) {
+	//prevent the golang compiler from pruning disassemblers
+	_DummyDisasm(false)
+
+	for _, arch := range []*sys.Arch{sys.Arch386, sys.ArchAMD64, sys.ArchARM64, sys.ArchRISCV64, sys.ArchLoong64} {
+		if _, ok := symPtr[disasmPkg+".disasm_"+arch.Name]; ok {
+			var f disasmFunc
+			*(*uintptr)(unsafe.Pointer(&f)) = getFuncPointer(symPtr, disasmPkg+".disasm_"+arch.Name)
+			disasmFuncs[arch.Name] = f
+		}
+	}
+}
+
+// Disassemble decodes the instruction at the beginning of code in go assembly syntax, pc is the address of code,
+// lookup returns the symbol and its address which contains addr, it can be nil
+func Disassemble(code []byte, pc uint64, arch *sys.Arch, lookup func(addr uint64) (string, uint64)) (text string, size int, err error) {
+	f, ok := disasmFuncs[arch.Name]
+	if !ok {
+		return text, size, fmt.Errorf("disassembler of arch:%s is not registered", arch.Name)
+	}
+	text, size = f(code, pc, lookup, arch.ByteOrder, false)
+	return text, size, nil
+}
@@ -29,6 +29,7 @@ var (
 
 func AddLinkName(symPtr map[string]uintptr) {
 	AddInstLinkName(symPtr)
+	addDisasmLinkName(symPtr)
 
 	*(*uintptr)(unsafe.Pointer(&_name)) = getFuncPointer(symPtr, "internal/abi.Name.Name")
 }
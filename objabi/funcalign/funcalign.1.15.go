@@ -22,6 +22,9 @@ func GetFuncAlign(arch *sys.Arch) int {
 	// see:^cmd/linker/internal/amd64/l.go
 	case sys.ArchAMD64.Name:
 		return 16
+	//see:^cmd/linker/internal/riscv64/l.go
+	case sys.ArchRISCV64.Name:
+		return 8
 	default:
 		panic(fmt.Errorf("not support arch:%s", arch.Name))
 	}
@@ -63,6 +63,19 @@ const (
 
 const (
 	//not used, only adapter golang higher version
+	R_CALLLOONG64        = 0x10000000 - 24
+	R_LOONG64_ADDR_HI    = 0x10000000 - 23
+	R_LOONG64_ADDR_LO    = 0x10000000 - 22
+	R_LOONG64_TLS_LE_HI  = 0x10000000 - 21
+	R_LOONG64_TLS_LE_LO  = 0x10000000 - 20
+	R_LOONG64_TLS_IE_HI  = 0x10000000 - 19
+	R_LOONG64_TLS_IE_LO  = 0x10000000 - 18
+	R_RISCV_JAL          = 0x10000000 - 17
+	R_RISCV_CALL         = 0x10000000 - 16
+	R_RISCV_PCREL_ITYPE  = 0x10000000 - 15
+	R_RISCV_PCREL_STYPE  = 0x10000000 - 14
+	R_RISCV_TLS_IE       = 0x10000000 - 13
+	R_RISCV_TLS_LE       = 0x10000000 - 12
 	R_KEEP               = 0x10000000 - 11
 	R_USENAMEDMETHOD     = 0x10000000 - 10
 	R_INITORDER          = 0x10000000 - 9
@@ -85,6 +85,52 @@ const (
 	// adrp followed by a LD64 or ST64 instruction.
 	R_ARM64_PCREL_LDST64 = (int)(objabi.R_ARM64_PCREL_LDST64)
 
+	// R_RISCV_JAL resolves a 20 bit offset for a J-type instruction.
+	R_RISCV_JAL = (int)(objabi.R_RISCV_JAL)
+
+	// R_RISCV_CALL resolves a 32 bit PC-relative address for an AUIPC + JALR
+	// instruction pair.
+	R_RISCV_CALL = (int)(objabi.R_RISCV_CALL)
+
+	// R_RISCV_PCREL_ITYPE resolves a 32 bit PC-relative address for an
+	// AUIPC + I-type instruction pair.
+	R_RISCV_PCREL_ITYPE = (int)(objabi.R_RISCV_PCREL_ITYPE)
+
+	// R_RISCV_PCREL_STYPE resolves a 32 bit PC-relative address for an
+	// AUIPC + S-type instruction pair.
+	R_RISCV_PCREL_STYPE = (int)(objabi.R_RISCV_PCREL_STYPE)
+
+	// R_RISCV_TLS_IE resolves a 32 bit TLS initial-exec address for an
+	// AUIPC + I-type instruction pair.
+	R_RISCV_TLS_IE = (int)(objabi.R_RISCV_TLS_IE)
+
+	// R_RISCV_TLS_LE resolves a 32 bit TLS local-exec address for a
+	// LUI + I-type instruction sequence.
+	R_RISCV_TLS_LE = (int)(objabi.R_RISCV_TLS_LE)
+
+	// R_LOONG64_ADDR_HI resolves [31...12]bits of 32/64-bit PC-relative offset of an
+	// external address, by encoding it into pcalau12i instruction.
+	// R_LOONG64_ADDR_LO resolves to the low 12 bits of an external address, by encoding
+	// it into addi.w/addi.d or load/store instruction.
+	R_LOONG64_ADDR_HI = (int)(objabi.R_LOONG64_ADDR_HI)
+	R_LOONG64_ADDR_LO = (int)(objabi.R_LOONG64_ADDR_LO)
+
+	// R_LOONG64_TLS_LE_HI resolves to the high 20 bits of a TLS address (offset from
+	// thread pointer), by encoding it into the instruction.
+	// R_LOONG64_TLS_LE_LO resolves to the low 12 bits of a TLS address (offset from
+	// thread pointer), by encoding it into the instruction.
+	R_LOONG64_TLS_LE_HI = (int)(objabi.R_LOONG64_TLS_LE_HI)
+	R_LOONG64_TLS_LE_LO = (int)(objabi.R_LOONG64_TLS_LE_LO)
+
+	// R_CALLLOONG64 resolves to the 28-bit 4-byte aligned PC-relative target
+	// address of a BL instruction, by encoding it into the instruction.
+	R_CALLLOONG64 = (int)(objabi.R_CALLLOONG64)
+
+	// R_LOONG64_TLS_IE_HI and R_LOONG64_TLS_IE_LO relocates a pcalau12i, ld.d
+	// pair to compute the address of the GOT slot of the tls symbol.
+	R_LOONG64_TLS_IE_HI = (int)(objabi.R_LOONG64_TLS_IE_HI)
+	R_LOONG64_TLS_IE_LO = (int)(objabi.R_LOONG64_TLS_IE_LO)
+
 	// R_INITORDER specifies an ordering edge between two inittask records.
 	// (From one p..inittask record to another one.)
 	// This relocation does not apply any changes to the actual data, it is
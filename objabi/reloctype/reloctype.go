@@ -3,7 +3,7 @@ package reloctype
 //go:inline
 func IsDirectCall(r int) bool {
 	switch r {
-	case R_CALL, R_CALLARM, R_CALLARM64:
+	case R_CALL, R_CALLARM, R_CALLARM64, R_RISCV_JAL, R_RISCV_CALL, R_CALLLOONG64:
 		return true
 	default:
 		return false
@@ -0,0 +1,234 @@
+// Package plugin loads go object files and serialized linkers with goloader,
+// its api is in the same shape as the standard plugin package.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+	"github.com/pkujhd/goloader/link"
+)
+
+// Symbol is a pointer to a variable or a function value, like a Symbol of the standard plugin package
+type Symbol interface{}
+
+// Func is a function of plugin whose type is unknown, it is returned by Lookup if the type is not read from
+// export data of objs or its type symbol is neither in plugin nor in host, use As to store it into a func variable
+type Func struct {
+	Name       string
+	Entry      uintptr
+	codeModule *goloader.CodeModule
+}
+
+// As stores the function into fnPtr, fnPtr must be a pointer to a func variable of the type of the function
+func (f *Func) As(fnPtr interface{}) error {
+	return f.codeModule.Func(f.Name, fnPtr)
+}
+
+// Plugin is a loaded goloader plugin
+type Plugin struct {
+	pluginpath string
+	pkgPaths   []string
+	codeModule *goloader.CodeModule
+	syms       map[string]Symbol
+	lock       sync.Mutex
+}
+
+var (
+	// host symbols are registered once, Load adds cgo symbols into symPtr, so it is guarded by lock
+	symPtr  map[string]uintptr
+	regErr  error
+	regOnce sync.Once
+
+	lock    sync.Mutex
+	plugins = make(map[string]*Plugin)
+)
+
+// register must be called before reading objs, RegSymbol also initializes the instruction decoder
+func register() error {
+	regOnce.Do(func() {
+		symPtr = make(map[string]uintptr)
+		regErr = goloader.RegSymbol(symPtr)
+	})
+	return regErr
+}
+
+// pkgPaths returns the packages of linker, the main package is the first
+func pkgPaths(linker *goloader.Linker) []string {
+	paths := make([]string, 0, len(linker.Packages))
+	for path := range linker.Packages {
+		if path != constants.DefaultPkgPath {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	if _, ok := linker.Packages[constants.DefaultPkgPath]; ok {
+		paths = append([]string{constants.DefaultPkgPath}, paths...)
+	}
+	return paths
+}
+
+func open(path string, read func() (*goloader.Linker, error)) (*Plugin, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if p, ok := plugins[path]; ok {
+		return p, nil
+	}
+	if err := register(); err != nil {
+		return nil, fmt.Errorf("plugin.Open(%q): %v", path, err)
+	}
+	linker, err := read()
+	if err != nil {
+		return nil, fmt.Errorf("plugin.Open(%q): %v", path, err)
+	}
+	codeModule, err := goloader.Load(linker, symPtr)
+	if err != nil {
+		return nil, fmt.Errorf("plugin.Open(%q): %v", path, err)
+	}
+	p := &Plugin{
+		pluginpath: path,
+		pkgPaths:   pkgPaths(linker),
+		codeModule: codeModule,
+		syms:       make(map[string]Symbol),
+	}
+	plugins[path] = p
+	return p, nil
+}
+
+// Open opens a serialized linker or a go object file of package main.
+// if trusted keys are set by goloader.SetTrustedKeys, only a serialized linker signed by one of them is opened.
+// if a path has already been opened, then the existing *Plugin is returned.
+func Open(path string) (*Plugin, error) {
+	realpath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin.Open(%q): %v", path, err)
+	}
+	return open(realpath, func() (*goloader.Linker, error) {
+		f, err := os.Open(realpath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		magic := make([]byte, len(link.SerializeMagic))
+		if _, err = io.ReadFull(f, magic); err == nil && string(magic) == link.SignedMagic && !link.SignedRequired() {
+			return nil, errors.New("bundle is signed, open it with OpenVerified or set trusted keys by goloader.SetTrustedKeys")
+		}
+		if err == nil && (string(magic) == link.SerializeMagic || string(magic) == link.SignedMagic) {
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return goloader.UnSerialize(f)
+		}
+		if link.SignedRequired() {
+			return nil, errors.New("go object file is not signed, trusted keys are set")
+		}
+		return goloader.ReadObj(realpath, constants.DefaultPkgPath)
+	})
+}
+
+// OpenObjs opens go object files of packages pkgPaths as one plugin
+func OpenObjs(files []string, pkgPaths []string) (*Plugin, error) {
+	if len(files) == 0 || len(files) != len(pkgPaths) {
+		return nil, errors.New("plugin.OpenObjs: files and pkgPaths mismatch")
+	}
+	realpaths := make([]string, len(files))
+	for index, file := range files {
+		realpath, err := filepath.Abs(file)
+		if err != nil {
+			return nil, fmt.Errorf("plugin.OpenObjs(%q): %v", file, err)
+		}
+		realpaths[index] = realpath
+	}
+	return open(fmt.Sprint(realpaths), func() (*goloader.Linker, error) {
+		if link.SignedRequired() {
+			return nil, errors.New("go object files are not signed, trusted keys are set")
+		}
+		return goloader.ReadObjs(realpaths, pkgPaths)
+	})
+}
+
+// OpenReader loads a serialized linker from reader, the returned plugin is not cached
+func OpenReader(reader io.Reader) (*Plugin, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if err := register(); err != nil {
+		return nil, fmt.Errorf("plugin.OpenReader: %v", err)
+	}
+	linker, err := goloader.UnSerialize(reader)
+	if err != nil {
+		return nil, fmt.Errorf("plugin.OpenReader: %v", err)
+	}
+	codeModule, err := goloader.Load(linker, symPtr)
+	if err != nil {
+		return nil, fmt.Errorf("plugin.OpenReader: %v", err)
+	}
+	return &Plugin{pkgPaths: pkgPaths(linker), codeModule: codeModule, syms: make(map[string]Symbol)}, nil
+}
+
+// Lookup searches for a symbol named symName in plugin, symName is searched in main package first,
+// then in every package of plugin, and at last as a full symbol name.
+// a variable is returned as a pointer to it, a function is returned as a func value which can be asserted to its type,
+// or *Func if its type is unknown
+func (p *Plugin) Lookup(symName string) (Symbol, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.codeModule == nil {
+		return nil, errors.New("plugin: " + p.pluginpath + " is closed")
+	}
+	if sym, ok := p.syms[symName]; ok {
+		return sym, nil
+	}
+	names := make([]string, 0, len(p.pkgPaths)+1)
+	for _, pkgPath := range p.pkgPaths {
+		names = append(names, pkgPath+"."+symName)
+	}
+	names = append(names, symName)
+	for _, name := range names {
+		if entry, ok := p.codeModule.Syms[name]; ok {
+			if fn, err := p.codeModule.LookupFunc(name); err == nil {
+				p.syms[symName] = fn
+			} else {
+				p.syms[symName] = &Func{Name: name, Entry: entry, codeModule: p.codeModule}
+			}
+			return p.syms[symName], nil
+		}
+		if ptr, err := p.codeModule.LookupVar(name); err == nil {
+			p.syms[symName] = ptr
+			return ptr, nil
+		}
+	}
+	return nil, errors.New("plugin: symbol " + symName + " not found in plugin " + p.pluginpath)
+}
+
+// CodeModule returns the goloader module of plugin
+func (p *Plugin) CodeModule() *goloader.CodeModule {
+	return p.codeModule
+}
+
+// Close unloads plugin, it fails if any goroutine is executing code of plugin or any module imports it.
+// symbols returned by Lookup must not be used after Close
+func (p *Plugin) Close() error {
+	lock.Lock()
+	defer lock.Unlock()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.codeModule == nil {
+		return nil
+	}
+	if err := p.codeModule.UnloadSafe(); err != nil {
+		return err
+	}
+	p.codeModule = nil
+	p.syms = nil
+	if plugins[p.pluginpath] == p {
+		delete(plugins, p.pluginpath)
+	}
+	return nil
+}
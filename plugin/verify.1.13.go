@@ -0,0 +1,29 @@
+//go:build go1.13
+// +build go1.13
+
+package plugin
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkujhd/goloader"
+)
+
+// OpenVerified opens a signed serialized linker, the signature is verified with trustedKeys before loading
+func OpenVerified(path string, trustedKeys []ed25519.PublicKey) (*Plugin, error) {
+	realpath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin.Open(%q): %v", path, err)
+	}
+	return open(realpath, func() (*goloader.Linker, error) {
+		f, err := os.Open(realpath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return goloader.UnSerializeVerified(f, trustedKeys)
+	})
+}
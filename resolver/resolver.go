@@ -0,0 +1,257 @@
+// Package resolver finds the packages which provide the unresolved symbols of a goloader.Linker,
+// and reads them with goloader.ReadDependPackages until every symbol is resolved.
+package resolver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/constants"
+)
+
+// Resolver locates the export archives of packages, in Dirs first, then with go list -export.
+// go list runs with GOPROXY=off, so only modules already in module cache and GOROOT are available
+type Resolver struct {
+	Dirs    []string // directories holding archives as <dir>/<import path>.a
+	WorkDir string   // working directory of go list, it decides the module of packages
+	Tags    string   // build tags passed to go list
+	NoGo    bool     // do not run go list, only search in Dirs
+
+	exports map[string]string
+	errors  map[string]string
+}
+
+// UnobtainableSymbol is a symbol which can not be resolved, and the reason
+type UnobtainableSymbol struct {
+	Name    string
+	PkgPath string
+	Reason  string
+}
+
+// UnobtainableError reports every symbol which can not be resolved
+type UnobtainableError struct {
+	Symbols []UnobtainableSymbol
+}
+
+func (e *UnobtainableError) Error() string {
+	lines := make([]string, 0, len(e.Symbols))
+	for _, sym := range e.Symbols {
+		if sym.PkgPath != constants.EmptyString {
+			lines = append(lines, fmt.Sprintf("%s (package %s): %s", sym.Name, sym.PkgPath, sym.Reason))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", sym.Name, sym.Reason))
+		}
+	}
+	return fmt.Sprintf("%d unobtainable symbol(s):\n\t%s", len(e.Symbols), strings.Join(lines, "\n\t"))
+}
+
+// PkgPath returns the import path of the package which defines symbol name,
+// it reverses the escaping of obj.PathToPrefix
+func PkgPath(name string) string {
+	name = strings.TrimPrefix(name, constants.TypePrefix)
+	if strings.HasPrefix(name, constants.ItabPrefix) {
+		// an itab is named by its concrete type and interface type
+		name = strings.TrimPrefix(name, constants.ItabPrefix)
+		if index := strings.IndexByte(name, ','); index >= 0 {
+			name = name[:index]
+		}
+	}
+	name = strings.TrimSuffix(name, constants.GOTPCRELSuffix)
+	name = strings.TrimLeft(name, "*[]0123456789")
+	if strings.HasPrefix(name, "map[") || strings.HasPrefix(name, "func(") || strings.HasPrefix(name, "chan ") {
+		return constants.EmptyString
+	}
+	// the type arguments and receivers of a symbol may contain '/' and '.'
+	prefix := name
+	if index := strings.IndexAny(prefix, "[("); index >= 0 {
+		prefix = prefix[:index]
+	}
+	start := strings.LastIndexByte(prefix, '/') + 1
+	dot := strings.IndexByte(name[start:], '.')
+	if dot < 0 {
+		return constants.EmptyString
+	}
+	return unescape(name[:start+dot])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func (r *Resolver) init() {
+	if r.exports == nil {
+		r.exports = make(map[string]string)
+		r.errors = make(map[string]string)
+	}
+}
+
+// goList finds the export archives of pkgPaths with go list, packages which fail are recorded in errors
+func (r *Resolver) goList(pkgPaths []string) {
+	args := []string{"list", "-e", "-export", "-f", "{{.ImportPath}}\t{{.Export}}\t{{if .Error}}{{.Error.Err}}{{end}}"}
+	if r.Tags != constants.EmptyString {
+		args = append(args, "-tags", r.Tags)
+	}
+	args = append(args, pkgPaths...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = r.WorkDir
+	cmd.Env = append(os.Environ(), "GOPROXY=off")
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		for _, pkgPath := range pkgPaths {
+			r.errors[pkgPath] = fmt.Sprintf("go list error: %v %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] != constants.EmptyString {
+			r.exports[fields[0]] = fields[1]
+		} else if fields[2] != constants.EmptyString {
+			r.errors[fields[0]] = fields[2]
+		}
+	}
+	for _, pkgPath := range pkgPaths {
+		if _, ok := r.exports[pkgPath]; !ok && r.errors[pkgPath] == constants.EmptyString {
+			r.errors[pkgPath] = "no export archive"
+		}
+	}
+}
+
+// Exports returns the export archives of pkgPaths, and the reasons of packages which are not found
+func (r *Resolver) Exports(pkgPaths []string) (exports map[string]string, errors map[string]string) {
+	r.init()
+	missing := make([]string, 0)
+	for _, pkgPath := range pkgPaths {
+		if _, ok := r.exports[pkgPath]; ok {
+			continue
+		}
+		for _, dir := range r.Dirs {
+			file := filepath.Join(dir, filepath.FromSlash(pkgPath)+".a")
+			if _, err := os.Stat(file); err == nil {
+				r.exports[pkgPath] = file
+				break
+			}
+		}
+		if _, ok := r.exports[pkgPath]; !ok && r.errors[pkgPath] == constants.EmptyString {
+			missing = append(missing, pkgPath)
+		}
+	}
+	if len(missing) > 0 {
+		if r.NoGo {
+			for _, pkgPath := range missing {
+				r.errors[pkgPath] = "not found in " + strings.Join(r.Dirs, string(os.PathListSeparator))
+			}
+		} else {
+			r.goList(missing)
+		}
+	}
+
+	exports = make(map[string]string)
+	errors = make(map[string]string)
+	for _, pkgPath := range pkgPaths {
+		if file, ok := r.exports[pkgPath]; ok {
+			exports[pkgPath] = file
+		} else {
+			errors[pkgPath] = r.errors[pkgPath]
+		}
+	}
+	return exports, errors
+}
+
+// Resolve reads the packages which provide the unresolved symbols of linker into linker,
+// until every symbol is resolved or no more package can be read.
+// packages linked in host are read like other packages, because the host linker strips their unused symbols,
+// symbols of them which are in symPtr are not added again.
+// the symbols which can not be resolved by any package are reported by *UnobtainableError
+func (r *Resolver) Resolve(linker *goloader.Linker, symPtr map[string]uintptr) error {
+	read := make(map[string]bool)
+	for pkgPath := range linker.Packages {
+		read[pkgPath] = true
+	}
+	unobtainable := make(map[string]UnobtainableSymbol)
+	for {
+		symbols := make([]string, 0)
+		pkgSymbols := make(map[string][]string)
+		for _, name := range goloader.UnresolvedSymbols(linker, symPtr) {
+			if _, ok := unobtainable[name]; ok {
+				continue
+			}
+			pkgPath := PkgPath(name)
+			switch {
+			case pkgPath == constants.EmptyString:
+				unobtainable[name] = UnobtainableSymbol{Name: name, Reason: "unknown package"}
+			case read[pkgPath]:
+				unobtainable[name] = UnobtainableSymbol{Name: name, PkgPath: pkgPath, Reason: "symbol is not defined in package"}
+			default:
+				symbols = append(symbols, name)
+				pkgSymbols[pkgPath] = append(pkgSymbols[pkgPath], name)
+			}
+		}
+		if len(pkgSymbols) == 0 {
+			break
+		}
+
+		pkgPaths := make([]string, 0, len(pkgSymbols))
+		for pkgPath := range pkgSymbols {
+			pkgPaths = append(pkgPaths, pkgPath)
+		}
+		sort.Strings(pkgPaths)
+		exports, errors := r.Exports(pkgPaths)
+		files := make([]string, 0, len(exports))
+		filePkgPaths := make([]string, 0, len(exports))
+		for _, pkgPath := range pkgPaths {
+			read[pkgPath] = true
+			if file, ok := exports[pkgPath]; ok {
+				files = append(files, file)
+				filePkgPaths = append(filePkgPaths, pkgPath)
+			} else {
+				for _, name := range pkgSymbols[pkgPath] {
+					unobtainable[name] = UnobtainableSymbol{Name: name, PkgPath: pkgPath, Reason: errors[pkgPath]}
+				}
+			}
+		}
+		if len(files) > 0 {
+			if err := goloader.ReadDependPackages(linker, files, filePkgPaths, symbols, symPtr); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(unobtainable) > 0 {
+		err := &UnobtainableError{}
+		for _, sym := range unobtainable {
+			err.Symbols = append(err.Symbols, sym)
+		}
+		sort.Slice(err.Symbols, func(i, j int) bool { return err.Symbols[i].Name < err.Symbols[j].Name })
+		return err
+	}
+	return nil
+}
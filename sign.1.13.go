@@ -0,0 +1,24 @@
+//go:build go1.13
+// +build go1.13
+
+package goloader
+
+import (
+	"crypto/ed25519"
+	"io"
+
+	"github.com/pkujhd/goloader/link"
+)
+
+func SerializeSigned(linker *Linker, writer io.Writer, privateKey ed25519.PrivateKey) error {
+	return link.SerializeSigned((*link.Linker)(linker), writer, privateKey)
+}
+
+func UnSerializeVerified(reader io.Reader, trustedKeys []ed25519.PublicKey) (*Linker, error) {
+	linker, err := link.UnSerializeVerified(reader, trustedKeys)
+	return (*Linker)(linker), err
+}
+
+func SetTrustedKeys(keys []ed25519.PublicKey) {
+	link.SetTrustedKeys(keys)
+}
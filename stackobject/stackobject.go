@@ -46,7 +46,7 @@ func addr2stackObjectRecords(addr unsafe.Pointer) *[]stackObjectRecord {
 func AddStackObject(funcname string, symMap map[string]*obj.Sym, symbolMap map[string]uintptr, noptrdata uintptr) (err error) {
 	Func := symMap[funcname].Func
 	if Func != nil && len(Func.FuncData) > dataindex.FUNCDATA_StackObjects &&
-		Func.FuncData[dataindex.FUNCDATA_StackObjects] != 0 {
+		Func.FuncData[dataindex.FUNCDATA_StackObjects] != constants.InvalidHandleValue {
 		objects := *addr2stackObjectRecords(adduintptr(Func.FuncData[dataindex.FUNCDATA_StackObjects], int(noptrdata)))
 		stkobjName := strings.TrimSuffix(funcname, constants.ABI0_SUFFIX) + constants.StkobjSuffix
 		for i := range objects {